@@ -4,9 +4,16 @@ import (
 	"bytes"
 	"fmt"
 	"github.com/px86/monkey/token"
+	"strconv"
 )
 
+// Node is implemented by every node of the AST. Pos is the position of
+// the first token of the node, and End the position immediately after
+// its last one, so that tools can map nodes back to their source.
+// Statements end with their last expression, excluding the ;.
 type Node interface {
+	Pos() token.Pos
+	End() token.Pos
 	String() string
 }
 
@@ -24,6 +31,19 @@ type Program struct {
 	Statements []Statement
 }
 
+func (p *Program) Pos() token.Pos {
+	if len(p.Statements) == 0 {
+		return token.NoPos
+	}
+	return p.Statements[0].Pos()
+}
+func (p *Program) End() token.Pos {
+	if len(p.Statements) == 0 {
+		return token.NoPos
+	}
+	return p.Statements[len(p.Statements)-1].End()
+}
+
 func (p *Program) String() string {
 	var out bytes.Buffer
 	out.WriteString("(prog ")
@@ -44,6 +64,19 @@ type ExpressionStatement struct {
 
 func (es *ExpressionStatement) statementNode() {}
 
+func (es *ExpressionStatement) Pos() token.Pos {
+	if es.Expression != nil {
+		return es.Expression.Pos()
+	}
+	return es.Token.Pos
+}
+func (es *ExpressionStatement) End() token.Pos {
+	if es.Expression != nil {
+		return es.Expression.End()
+	}
+	return es.Token.End
+}
+
 func (es *ExpressionStatement) String() string {
 	if es.Expression != nil {
 		return es.Expression.String()
@@ -54,10 +87,14 @@ func (es *ExpressionStatement) String() string {
 type BlockStatement struct {
 	Token      token.Token // of type token.LEFT_BRACE
 	Statements []Statement
+	Rbrace     token.Token // the closing }
 }
 
 func (bs *BlockStatement) statementNode() {}
 
+func (bs *BlockStatement) Pos() token.Pos { return bs.Token.Pos }
+func (bs *BlockStatement) End() token.Pos { return bs.Rbrace.End }
+
 func (bs *BlockStatement) String() string {
 	var out bytes.Buffer
 	out.WriteString("(block ")
@@ -79,6 +116,14 @@ type IfExpression struct {
 }
 
 func (ie *IfExpression) expressionNode() {}
+
+func (ie *IfExpression) Pos() token.Pos { return ie.Token.Pos }
+func (ie *IfExpression) End() token.Pos {
+	if ie.ElseBlock != nil {
+		return ie.ElseBlock.End()
+	}
+	return ie.ThenBlock.End()
+}
 func (ie *IfExpression) String() string {
 	if ie.ElseBlock != nil {
 		return fmt.Sprintf("(%s %s %s %s)", token.AsString(ie.Token.Type),
@@ -101,6 +146,9 @@ func (b *Boolean) String() string {
 }
 func (b *Boolean) expressionNode() {}
 
+func (b *Boolean) Pos() token.Pos { return b.Token.Pos }
+func (b *Boolean) End() token.Pos { return b.Token.End }
+
 type IntegerLiteral struct {
 	Token token.Token
 	Value int64
@@ -111,6 +159,22 @@ func (i *IntegerLiteral) String() string {
 }
 func (i *IntegerLiteral) expressionNode() {}
 
+func (i *IntegerLiteral) Pos() token.Pos { return i.Token.Pos }
+func (i *IntegerLiteral) End() token.Pos { return i.Token.End }
+
+type FloatLiteral struct {
+	Token token.Token
+	Value float64
+}
+
+func (f *FloatLiteral) String() string {
+	return strconv.FormatFloat(f.Value, 'g', -1, 64)
+}
+func (f *FloatLiteral) expressionNode() {}
+
+func (f *FloatLiteral) Pos() token.Pos { return f.Token.Pos }
+func (f *FloatLiteral) End() token.Pos { return f.Token.End }
+
 type StringLiteral struct {
 	Token token.Token
 	Value string
@@ -121,6 +185,32 @@ func (s *StringLiteral) String() string {
 }
 func (s *StringLiteral) expressionNode() {}
 
+func (s *StringLiteral) Pos() token.Pos { return s.Token.Pos }
+func (s *StringLiteral) End() token.Pos { return s.Token.End }
+
+// InterpolatedString is a string literal with embedded expressions. Its
+// parts are string literals for the text between the expressions, and
+// the expressions themselves.
+type InterpolatedString struct {
+	Token token.Token
+	Parts []Expression
+}
+
+func (is *InterpolatedString) String() string {
+	var buff bytes.Buffer
+	buff.WriteString("(interp")
+	for _, part := range is.Parts {
+		buff.WriteString(" ")
+		buff.WriteString(part.String())
+	}
+	buff.WriteString(")")
+	return buff.String()
+}
+func (is *InterpolatedString) expressionNode() {}
+
+func (is *InterpolatedString) Pos() token.Pos { return is.Token.Pos }
+func (is *InterpolatedString) End() token.Pos { return is.Token.End }
+
 type PrefixExpr struct {
 	Operator   token.Token
 	Expression Expression
@@ -131,6 +221,11 @@ func (pe *PrefixExpr) String() string {
 }
 func (pe *PrefixExpr) expressionNode() {}
 
+// The operator of a PrefixExpr registered as a postfix operator follows
+// the expression.
+func (pe *PrefixExpr) Pos() token.Pos { return min(pe.Operator.Pos, pe.Expression.Pos()) }
+func (pe *PrefixExpr) End() token.Pos { return max(pe.Operator.End, pe.Expression.End()) }
+
 type InfixExpr struct {
 	Left     Expression
 	Operator token.Token
@@ -142,6 +237,36 @@ func (be *InfixExpr) String() string {
 }
 func (be *InfixExpr) expressionNode() {}
 
+func (be *InfixExpr) Pos() token.Pos { return be.Left.Pos() }
+func (be *InfixExpr) End() token.Pos { return be.Right.End() }
+
+// GroupedExpr is an expression in parentheses. It only records where the
+// parentheses are, and prints as the expression itself.
+type GroupedExpr struct {
+	Lparen     token.Token
+	Expression Expression
+	Rparen     token.Token
+}
+
+func (ge *GroupedExpr) String() string {
+	return ge.Expression.String()
+}
+func (ge *GroupedExpr) expressionNode() {}
+
+func (ge *GroupedExpr) Pos() token.Pos { return ge.Lparen.Pos }
+func (ge *GroupedExpr) End() token.Pos { return ge.Rparen.End }
+
+// Unparen returns e with any enclosing parentheses removed.
+func Unparen(e Expression) Expression {
+	for {
+		ge, ok := e.(*GroupedExpr)
+		if !ok {
+			return e
+		}
+		e = ge.Expression
+	}
+}
+
 type FunctionExpr struct {
 	Token token.Token // fn
 	Args  []*Identifier
@@ -164,14 +289,21 @@ func (fe *FunctionExpr) String() string {
 }
 func (fe *FunctionExpr) expressionNode() {}
 
+func (fe *FunctionExpr) Pos() token.Pos { return fe.Token.Pos }
+func (fe *FunctionExpr) End() token.Pos { return fe.Body.End() }
+
 type LetStatement struct {
 	Token token.Token
 	Name  *Identifier
 	Value Expression
+	Doc   string // text of the /// comments preceding the statement, if any
 }
 
 func (ls *LetStatement) statementNode() {}
 
+func (ls *LetStatement) Pos() token.Pos { return ls.Token.Pos }
+func (ls *LetStatement) End() token.Pos { return ls.Value.End() }
+
 func (ls *LetStatement) String() string {
 	kwlet, _ := ls.Token.Value.(string) // "let"
 	return fmt.Sprintf("(%s %s %s)", kwlet, ls.Name.String(), ls.Value.String())
@@ -184,22 +316,30 @@ type Identifier struct {
 
 func (i *Identifier) expressionNode() {}
 
+func (i *Identifier) Pos() token.Pos { return i.Token.Pos }
+func (i *Identifier) End() token.Pos { return i.Token.End }
+
 func (i *Identifier) String() string {
 	s, _ := i.Token.Value.(string)
 	return s
 }
 
 type FunctionCall struct {
-	Name *Identifier
-	Args []Expression
+	Token    token.Token // (
+	Function Expression  // identifier, function literal or any expression evaluating to a function
+	Args     []Expression
+	Rparen   token.Token // )
 }
 
 func (fc *FunctionCall) expressionNode() {}
 
+func (fc *FunctionCall) Pos() token.Pos { return fc.Function.Pos() }
+func (fc *FunctionCall) End() token.Pos { return fc.Rparen.End }
+
 func (fc *FunctionCall) String() string {
 	var buff bytes.Buffer
 	buff.WriteString("(")
-	buff.WriteString(fc.Name.String())
+	buff.WriteString(fc.Function.String())
 	for _, arg := range fc.Args {
 		buff.WriteString(" ")
 		buff.WriteString(arg.String())
@@ -215,7 +355,227 @@ type ReturnStatement struct {
 
 func (rs *ReturnStatement) statementNode() {}
 
+func (rs *ReturnStatement) Pos() token.Pos { return rs.Token.Pos }
+func (rs *ReturnStatement) End() token.Pos { return rs.ReturnValue.End() }
+
 func (rs *ReturnStatement) String() string {
 	rtrn, _ := rs.Token.Value.(string) // "return"
 	return fmt.Sprintf("(%s %s)", rtrn, rs.ReturnValue.String())
 }
+
+// A loop may be labeled, as in outer: while (...) { ... }, so that break
+// and continue can refer to it from nested loops. Label is nil if not.
+func labeled(label *Identifier, loop string) string {
+	if label == nil {
+		return loop
+	}
+	return fmt.Sprintf("(label %s %s)", label.Value, loop)
+}
+
+// Return the position of a loop, which starts at its label if any.
+func loopPos(label *Identifier, tok token.Token) token.Pos {
+	if label != nil {
+		return label.Pos()
+	}
+	return tok.Pos
+}
+
+type WhileStatement struct {
+	Token     token.Token // while
+	Label     *Identifier
+	Condition Expression
+	Body      *BlockStatement
+}
+
+func (ws *WhileStatement) statementNode() {}
+
+func (ws *WhileStatement) Pos() token.Pos { return loopPos(ws.Label, ws.Token) }
+func (ws *WhileStatement) End() token.Pos { return ws.Body.End() }
+
+func (ws *WhileStatement) String() string {
+	return labeled(ws.Label, fmt.Sprintf("(while %s %s)", ws.Condition.String(), ws.Body.String()))
+}
+
+// ForStatement is for (Init; Condition; Update) Body. Each of Init,
+// Condition and Update is nil when omitted; the loop runs forever without
+// a Condition.
+type ForStatement struct {
+	Token     token.Token // for
+	Label     *Identifier
+	Init      Statement // a *LetStatement or an *ExpressionStatement
+	Condition Expression
+	Update    Expression
+	Body      *BlockStatement
+}
+
+func (fs *ForStatement) statementNode() {}
+
+func (fs *ForStatement) Pos() token.Pos { return loopPos(fs.Label, fs.Token) }
+func (fs *ForStatement) End() token.Pos { return fs.Body.End() }
+
+func (fs *ForStatement) String() string {
+	init, cond, update := "_", "_", "_"
+	if fs.Init != nil {
+		init = fs.Init.String()
+	}
+	if fs.Condition != nil {
+		cond = fs.Condition.String()
+	}
+	if fs.Update != nil {
+		update = fs.Update.String()
+	}
+	return labeled(fs.Label, fmt.Sprintf("(for %s %s %s %s)", init, cond, update, fs.Body.String()))
+}
+
+// ForInStatement is for (Variable in Iterable) Body.
+type ForInStatement struct {
+	Token    token.Token // for
+	Label    *Identifier
+	Variable *Identifier
+	Iterable Expression
+	Body     *BlockStatement
+}
+
+func (fs *ForInStatement) statementNode() {}
+
+func (fs *ForInStatement) Pos() token.Pos { return loopPos(fs.Label, fs.Token) }
+func (fs *ForInStatement) End() token.Pos { return fs.Body.End() }
+
+func (fs *ForInStatement) String() string {
+	return labeled(fs.Label, fmt.Sprintf("(for-in %s %s %s)",
+		fs.Variable.String(), fs.Iterable.String(), fs.Body.String()))
+}
+
+// BranchStatement is break or continue, with the label of the loop it
+// applies to, or nil for the innermost loop.
+type BranchStatement struct {
+	Token token.Token // break or continue
+	Label *Identifier
+}
+
+func (bs *BranchStatement) statementNode() {}
+
+func (bs *BranchStatement) Pos() token.Pos { return bs.Token.Pos }
+func (bs *BranchStatement) End() token.Pos {
+	if bs.Label != nil {
+		return bs.Label.End()
+	}
+	return bs.Token.End
+}
+
+func (bs *BranchStatement) String() string {
+	if bs.Label == nil {
+		return fmt.Sprintf("(%s)", token.AsString(bs.Token.Type))
+	}
+	return fmt.Sprintf("(%s %s)", token.AsString(bs.Token.Type), bs.Label.Value)
+}
+
+type ArrayLiteral struct {
+	Token    token.Token // [
+	Elements []Expression
+	Rbracket token.Token // ]
+}
+
+func (al *ArrayLiteral) expressionNode() {}
+
+func (al *ArrayLiteral) Pos() token.Pos { return al.Token.Pos }
+func (al *ArrayLiteral) End() token.Pos { return al.Rbracket.End }
+
+func (al *ArrayLiteral) String() string {
+	var buff bytes.Buffer
+	buff.WriteString("(array")
+	for _, el := range al.Elements {
+		buff.WriteString(" ")
+		buff.WriteString(el.String())
+	}
+	buff.WriteString(")")
+	return buff.String()
+}
+
+type IndexExpr struct {
+	Token    token.Token // [
+	Left     Expression
+	Index    Expression
+	Rbracket token.Token // ]
+}
+
+func (ie *IndexExpr) expressionNode() {}
+
+func (ie *IndexExpr) Pos() token.Pos { return ie.Left.Pos() }
+func (ie *IndexExpr) End() token.Pos { return ie.Rbracket.End }
+
+func (ie *IndexExpr) String() string {
+	return fmt.Sprintf("(index %s %s)", ie.Left.String(), ie.Index.String())
+}
+
+// AssignExpr is Target = Value, or a compound assignment like
+// Target += Value. Target is an *Identifier or an *IndexExpr.
+type AssignExpr struct {
+	Token  token.Token // =, +=, -=, *= or /=
+	Target Expression
+	Value  Expression
+}
+
+func (ae *AssignExpr) expressionNode() {}
+
+func (ae *AssignExpr) Pos() token.Pos { return ae.Target.Pos() }
+func (ae *AssignExpr) End() token.Pos { return ae.Value.End() }
+
+func (ae *AssignExpr) String() string {
+	return fmt.Sprintf("(%s %s %s)", token.AsString(ae.Token.Type), ae.Target.String(), ae.Value.String())
+}
+
+// SliceExpr is Left[Low:High]. Low and High are nil when omitted.
+type SliceExpr struct {
+	Token    token.Token // [
+	Left     Expression
+	Low      Expression
+	High     Expression
+	Rbracket token.Token // ]
+}
+
+func (se *SliceExpr) expressionNode() {}
+
+func (se *SliceExpr) Pos() token.Pos { return se.Left.Pos() }
+func (se *SliceExpr) End() token.Pos { return se.Rbracket.End }
+
+func (se *SliceExpr) String() string {
+	low, high := "_", "_"
+	if se.Low != nil {
+		low = se.Low.String()
+	}
+	if se.High != nil {
+		high = se.High.String()
+	}
+	return fmt.Sprintf("(slice %s %s %s)", se.Left.String(), low, high)
+}
+
+type HashLiteral struct {
+	Token  token.Token // {
+	Pairs  []HashPair  // in source order
+	Rbrace token.Token // }
+}
+
+type HashPair struct {
+	Key   Expression
+	Value Expression
+}
+
+func (hl *HashLiteral) expressionNode() {}
+
+func (hl *HashLiteral) Pos() token.Pos { return hl.Token.Pos }
+func (hl *HashLiteral) End() token.Pos { return hl.Rbrace.End }
+
+func (hl *HashLiteral) String() string {
+	var buff bytes.Buffer
+	buff.WriteString("(hash")
+	for _, pair := range hl.Pairs {
+		buff.WriteString(" (")
+		buff.WriteString(pair.Key.String())
+		buff.WriteString(" ")
+		buff.WriteString(pair.Value.String())
+		buff.WriteString(")")
+	}
+	buff.WriteString(")")
+	return buff.String()
+}
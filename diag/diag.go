@@ -0,0 +1,215 @@
+// Package diag renders diagnostics in the style of rustc: a headline with
+// the message, the location, the offending source line with the span
+// underlined, and any notes and suggestions.
+//
+//	error: unexpected character '@'
+//	 --> script.monkey:1:9
+//	  |
+//	1 | let x = @;
+//	  |         ^
+//	  = help: remove the character
+package diag
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/px86/monkey/lexer"
+	"github.com/px86/monkey/parser"
+	"github.com/px86/monkey/token"
+)
+
+type Severity int
+
+const (
+	Error Severity = iota
+	Warning
+)
+
+func (s Severity) String() string {
+	if s == Warning {
+		return "warning"
+	}
+	return "error"
+}
+
+// Diagnostic is a message about the source delimited by Pos and End. The
+// span may be empty, and Pos may be token.NoPos for messages that are not
+// about any particular source.
+type Diagnostic struct {
+	Severity Severity
+	Code     string // shown after the severity, like error[E0001]
+	Pos      token.Pos
+	End      token.Pos
+	Message  string
+	Notes    []string
+	Help     []string // suggestions on how to fix the problem
+}
+
+// FromError returns a diagnostic for err, spanning the source it reports
+// if it is known.
+func FromError(err error) Diagnostic {
+	var lexErr *lexer.Error
+	if errors.As(err, &lexErr) {
+		d := Diagnostic{Pos: lexErr.Pos, End: lexErr.End, Message: lexErr.Msg}
+		if lexErr.Help != "" {
+			d.Help = []string{lexErr.Help}
+		}
+		return d
+	}
+	var syntaxErr *parser.SyntaxError
+	if errors.As(err, &syntaxErr) {
+		return Diagnostic{
+			Code:    syntaxErr.Code.String(),
+			Pos:     syntaxErr.Pos,
+			End:     syntaxErr.End,
+			Message: syntaxErr.Msg,
+		}
+	}
+	return Diagnostic{Message: err.Error()}
+}
+
+// ANSI escape sequences used when colour is enabled.
+const (
+	bold   = "\x1b[1m"
+	red    = "\x1b[1;31m"
+	yellow = "\x1b[1;33m"
+	blue   = "\x1b[1;34m"
+	reset  = "\x1b[0m"
+)
+
+// Printer writes diagnostics about the files of a token.FileSet. The
+// lexer does not keep the source it reads, so lines are fetched from
+// sources registered with AddSource, or else read again from disk.
+type Printer struct {
+	Color bool // set by NewPrinter if out is a terminal
+
+	out     io.Writer
+	fset    *token.FileSet
+	sources map[*token.File]string
+}
+
+func NewPrinter(out io.Writer, fset *token.FileSet) *Printer {
+	return &Printer{
+		Color:   isTerminal(out),
+		out:     out,
+		fset:    fset,
+		sources: map[*token.File]string{},
+	}
+}
+
+// AddSource records the source of file, for files which are not on disk,
+// like lines typed into the REPL.
+func (p *Printer) AddSource(file *token.File, src string) {
+	p.sources[file] = src
+}
+
+// Print writes d to the output of p.
+func (p *Printer) Print(d Diagnostic) {
+	severityColor := red
+	if d.Severity == Warning {
+		severityColor = yellow
+	}
+	headline := d.Severity.String()
+	if d.Code != "" {
+		headline += "[" + d.Code + "]"
+	}
+	fmt.Fprintf(p.out, "%s%s\n",
+		p.paint(severityColor, headline+":"),
+		p.paint(bold, " "+d.Message))
+
+	pos := p.fset.Position(d.Pos)
+	gutter := ""
+	if pos.IsValid() {
+		gutter = strings.Repeat(" ", len(strconv.Itoa(pos.Line)))
+		fmt.Fprintf(p.out, "%s%s %s\n", gutter, p.paint(blue, "-->"), pos)
+		if line, ok := p.sourceLine(d.Pos, pos.Line); ok {
+			underline := underline(line, pos, p.fset.Position(d.End))
+			fmt.Fprintf(p.out, "%s\n", p.paint(blue, gutter+" |"))
+			fmt.Fprintf(p.out, "%s %s\n", p.paint(blue, strconv.Itoa(pos.Line)+" |"), line)
+			fmt.Fprintf(p.out, "%s %s\n", p.paint(blue, gutter+" |"), p.paint(severityColor, underline))
+		}
+	}
+	for _, note := range d.Notes {
+		fmt.Fprintf(p.out, "%s %s %s\n", gutter, p.paint(blue, "="), p.paint(bold, "note:")+" "+note)
+	}
+	for _, help := range d.Help {
+		fmt.Fprintf(p.out, "%s %s %s\n", gutter, p.paint(blue, "="), p.paint(bold, "help:")+" "+help)
+	}
+}
+
+func (p *Printer) paint(color, s string) string {
+	if !p.Color {
+		return s
+	}
+	return color + s + reset
+}
+
+// Return the text of the given line of the file containing pos, without
+// the line terminator.
+func (p *Printer) sourceLine(pos token.Pos, line int) (string, bool) {
+	file := p.fset.File(pos)
+	src, ok := p.sources[file]
+	if !ok {
+		data, err := os.ReadFile(file.Name())
+		if err != nil {
+			return "", false
+		}
+		src = string(data)
+		p.sources[file] = src
+	}
+
+	start := file.LineStart(line)
+	if start < 0 || start > len(src) {
+		return "", false
+	}
+	text := src[start:]
+	if i := strings.IndexByte(text, '\n'); i >= 0 {
+		text = text[:i]
+	}
+	return strings.TrimRight(text, "\r"), true
+}
+
+// Return the marker placed under line for the span from start to end:
+// carets under the span, or a single caret if it is empty or its end is
+// unknown. Spans reaching past the line are underlined up to its end.
+func underline(line string, start, end token.Position) string {
+	width := 1
+	if end.Line == start.Line {
+		width = end.Column - start.Column
+	} else if end.Line > start.Line {
+		width = utf8.RuneCountInString(line) - start.Column
+	}
+
+	// copy the tabs of the line, so that the carets stay aligned
+	var out strings.Builder
+	column := 0
+	for _, c := range line {
+		if column == start.Column {
+			break
+		}
+		if c == '\t' {
+			out.WriteRune('\t')
+		} else {
+			out.WriteRune(' ')
+		}
+		column++
+	}
+	out.WriteString(strings.Repeat("^", max(width, 1)))
+	return out.String()
+}
+
+// Report whether w is a terminal which is not asked to go without colour.
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok || os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
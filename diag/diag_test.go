@@ -0,0 +1,193 @@
+package diag
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/px86/monkey/lexer"
+	"github.com/px86/monkey/parser"
+	"github.com/px86/monkey/token"
+)
+
+// Lex src as a file of fset, returning the file and the lexer errors.
+func lex(fset *token.FileSet, name, src string) (*token.File, []error) {
+	file := fset.AddFile(name)
+	l := lexer.NewFileReader(file, strings.NewReader(src))
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+	}
+	return file, l.Errors
+}
+
+func TestPrintLexerErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{
+			"let x = @;",
+			`error: unexpected character '@'
+ --> test.monkey:1:9
+  |
+1 | let x = @;
+  |         ^
+  = help: remove the character
+`,
+		},
+		{
+			"let s = \"a\\qb\";",
+			`error: unknown escape sequence \q
+ --> test.monkey:1:11
+  |
+1 | let s = "a\qb";
+  |           ^^
+`,
+		},
+		{
+			"\tlet größe = 0x;",
+			`error: hexadecimal literal 0x has no digits
+ --> test.monkey:1:14
+  |
+1 | 	let größe = 0x;
+  | 	            ^^
+`,
+		},
+		{
+			"1;\n2;\n3;\n4;\n5;\n6;\n7;\n8;\n9;\nlet s = \"abc\n",
+			`error: unterminated string literal
+  --> test.monkey:10:9
+   |
+10 | let s = "abc
+   |         ^^^^
+   = help: add the closing "
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		fset := token.NewFileSet()
+		file, errs := lex(fset, "test.monkey", tt.input)
+		if len(errs) != 1 {
+			t.Fatalf("expected 1 error for %q, got=%v", tt.input, errs)
+		}
+
+		var out bytes.Buffer
+		p := NewPrinter(&out, fset)
+		p.AddSource(file, tt.input)
+		p.Print(FromError(errs[0]))
+		if out.String() != tt.expected {
+			t.Errorf("output wrong for %q.\nexpected=\n%s\ngot=\n%s", tt.input, tt.expected, out.String())
+		}
+	}
+}
+
+func TestPrintNotesAndHelp(t *testing.T) {
+	fset := token.NewFileSet()
+	lex(fset, "a.monkey", "let a = 1;\n")
+	file, _ := lex(fset, "b.monkey", "let b = a +\n  2;")
+	start := file.Pos(8)
+
+	var out bytes.Buffer
+	p := NewPrinter(&out, fset)
+	p.AddSource(file, "let b = a +\n  2;")
+	p.Print(Diagnostic{
+		Severity: Warning,
+		Pos:      start,
+		End:      file.Pos(14),
+		Message:  "expression spans lines",
+		Notes:    []string{"first note", "second note"},
+		Help:     []string{"join the lines"},
+	})
+	expected := `warning: expression spans lines
+ --> b.monkey:1:9
+  |
+1 | let b = a +
+  |         ^^^
+  = note: first note
+  = note: second note
+  = help: join the lines
+`
+	if out.String() != expected {
+		t.Errorf("output wrong.\nexpected=\n%s\ngot=\n%s", expected, out.String())
+	}
+}
+
+func TestPrintWithoutSource(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "script.monkey")
+	src := "let x = 1;\r\nlet y = ~~;\r\n"
+	if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	fset := token.NewFileSet()
+	file, errs := lex(fset, path, src)
+	var out bytes.Buffer
+	p := NewPrinter(&out, fset)
+	// the source is read from disk
+	p.Print(Diagnostic{Pos: file.Pos(20), Message: "unexpected ~"})
+	expected := `error: unexpected ~
+ --> ` + path + `:2:9
+  |
+2 | let y = ~~;
+  |         ^
+`
+	if len(errs) != 0 || out.String() != expected {
+		t.Errorf("output wrong.\nexpected=\n%s\ngot=\n%s", expected, out.String())
+	}
+
+	// the source is gone, and no position is known
+	os.Remove(path)
+	out.Reset()
+	p = NewPrinter(&out, fset)
+	p.Print(Diagnostic{Pos: file.Pos(20), Message: "unexpected ~"})
+	p.Print(FromError(errors.New("something failed")))
+	expected = `error: unexpected ~
+ --> ` + path + `:2:9
+error: something failed
+`
+	if out.String() != expected {
+		t.Errorf("output wrong.\nexpected=\n%s\ngot=\n%s", expected, out.String())
+	}
+}
+
+func TestColor(t *testing.T) {
+	fset := token.NewFileSet()
+	var out bytes.Buffer
+	p := NewPrinter(&out, fset)
+	if p.Color {
+		t.Errorf("colour enabled for a buffer")
+	}
+	p.Color = true
+	p.Print(Diagnostic{Message: "boom"})
+	if expected := "\x1b[1;31merror:\x1b[0m\x1b[1m boom\x1b[0m\n"; out.String() != expected {
+		t.Errorf("output wrong. expected=%q, got=%q", expected, out.String())
+	}
+}
+
+func TestPrintSyntaxError(t *testing.T) {
+	src := "let x = (1 + 2;"
+	fset := token.NewFileSet()
+	file := fset.AddFile("test.monkey")
+	p := parser.New(lexer.NewFileReader(file, strings.NewReader(src)))
+	p.ParseProgram()
+	if len(p.Errors) != 1 {
+		t.Fatalf("expected 1 error, got=%v", p.Errors)
+	}
+
+	var out bytes.Buffer
+	printer := NewPrinter(&out, fset)
+	printer.AddSource(file, src)
+	printer.Print(FromError(p.Errors[0]))
+	expected := `error[E0001]: expected ), got=;
+ --> test.monkey:1:15
+  |
+1 | let x = (1 + 2;
+  |               ^
+`
+	if out.String() != expected {
+		t.Errorf("output wrong.\nexpected=\n%s\ngot=\n%s", expected, out.String())
+	}
+}
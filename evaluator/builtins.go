@@ -0,0 +1,343 @@
+package evaluator
+
+import (
+	"github.com/px86/monkey/object"
+	"github.com/px86/monkey/token"
+	"math"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Functions available in every environment. A let binding with the same
+// name shadows the builtin.
+var builtins = map[string]*object.Builtin{
+	"len":   {Name: "len", Fn: builtinLen},
+	"first": {Name: "first", Fn: builtinFirst},
+	"last":  {Name: "last", Fn: builtinLast},
+	"rest":  {Name: "rest", Fn: builtinRest},
+	"push":  {Name: "push", Fn: builtinPush},
+	"range": {Name: "range", Fn: builtinRange},
+
+	"int":   {Name: "int", Fn: builtinInt},
+	"float": {Name: "float", Fn: builtinFloat},
+
+	"split":    {Name: "split", Fn: builtinSplit},
+	"join":     {Name: "join", Fn: builtinJoin},
+	"upper":    {Name: "upper", Fn: builtinUpper},
+	"lower":    {Name: "lower", Fn: builtinLower},
+	"trim":     {Name: "trim", Fn: builtinTrim},
+	"contains": {Name: "contains", Fn: builtinContains},
+	"replace":  {Name: "replace", Fn: builtinReplace},
+	"format":   {Name: "format", Fn: builtinFormat},
+}
+
+func checkArgCount(name string, call token.Token, args []object.Object, expected int) *object.Error {
+	if len(args) != expected {
+		return newError(object.ARGUMENT_ERROR, call,
+			"wrong number of arguments to %s: expected=%d, got=%d", name, expected, len(args))
+	}
+	return nil
+}
+
+func checkMinArgCount(name string, call token.Token, args []object.Object, min int) *object.Error {
+	if len(args) < min {
+		return newError(object.ARGUMENT_ERROR, call,
+			"wrong number of arguments to %s: expected at least %d, got=%d", name, min, len(args))
+	}
+	return nil
+}
+
+// Check that all args are strings and return their values.
+func stringArgs(name string, call token.Token, args []object.Object) ([]string, *object.Error) {
+	values := make([]string, len(args))
+	for i, arg := range args {
+		str, ok := arg.(*object.String)
+		if !ok {
+			return nil, argumentTypeError(name, call, arg)
+		}
+		values[i] = str.Value
+	}
+	return values, nil
+}
+
+func argumentTypeError(name string, call token.Token, arg object.Object) *object.Error {
+	return newError(object.TYPE_ERROR, call, "argument to %s not supported: got=%s", name, arg.Type())
+}
+
+func builtinLen(call token.Token, args ...object.Object) object.Object {
+	if err := checkArgCount("len", call, args, 1); err != nil {
+		return err
+	}
+	switch arg := args[0].(type) {
+	case *object.Array:
+		return &object.Integer{Value: int64(len(arg.Elements))}
+	case *object.String:
+		return &object.Integer{Value: int64(utf8.RuneCountInString(arg.Value))}
+	case *object.Hash:
+		return &object.Integer{Value: int64(arg.Len())}
+	case *object.Range:
+		return &object.Integer{Value: arg.Len()}
+	default:
+		return argumentTypeError("len", call, arg)
+	}
+}
+
+func builtinFirst(call token.Token, args ...object.Object) object.Object {
+	if err := checkArgCount("first", call, args, 1); err != nil {
+		return err
+	}
+	array, ok := args[0].(*object.Array)
+	if !ok {
+		return argumentTypeError("first", call, args[0])
+	}
+	if len(array.Elements) == 0 {
+		return NULL
+	}
+	return array.Elements[0]
+}
+
+func builtinLast(call token.Token, args ...object.Object) object.Object {
+	if err := checkArgCount("last", call, args, 1); err != nil {
+		return err
+	}
+	array, ok := args[0].(*object.Array)
+	if !ok {
+		return argumentTypeError("last", call, args[0])
+	}
+	if len(array.Elements) == 0 {
+		return NULL
+	}
+	return array.Elements[len(array.Elements)-1]
+}
+
+// Return a new array containing every element but the first.
+func builtinRest(call token.Token, args ...object.Object) object.Object {
+	if err := checkArgCount("rest", call, args, 1); err != nil {
+		return err
+	}
+	array, ok := args[0].(*object.Array)
+	if !ok {
+		return argumentTypeError("rest", call, args[0])
+	}
+	if len(array.Elements) == 0 {
+		return NULL
+	}
+	elements := make([]object.Object, len(array.Elements)-1)
+	copy(elements, array.Elements[1:])
+	return &object.Array{Elements: elements}
+}
+
+// Return a new array with the element appended, leaving the original
+// array untouched.
+func builtinPush(call token.Token, args ...object.Object) object.Object {
+	if err := checkArgCount("push", call, args, 2); err != nil {
+		return err
+	}
+	array, ok := args[0].(*object.Array)
+	if !ok {
+		return argumentTypeError("push", call, args[0])
+	}
+	elements := make([]object.Object, len(array.Elements), len(array.Elements)+1)
+	copy(elements, array.Elements)
+	elements = append(elements, args[1])
+	return &object.Array{Elements: elements}
+}
+
+// Return the range of integers range(stop), range(start, stop) or
+// range(start, stop, step). start defaults to 0, and step to 1.
+func builtinRange(call token.Token, args ...object.Object) object.Object {
+	if len(args) < 1 || len(args) > 3 {
+		return newError(object.ARGUMENT_ERROR, call,
+			"wrong number of arguments to range: expected 1 to 3, got=%d", len(args))
+	}
+	bounds := make([]int64, len(args))
+	for i, arg := range args {
+		integer, ok := arg.(*object.Integer)
+		if !ok {
+			return argumentTypeError("range", call, arg)
+		}
+		bounds[i] = integer.Value
+	}
+	r := &object.Range{Step: 1}
+	switch len(bounds) {
+	case 1:
+		r.Stop = bounds[0]
+	case 2:
+		r.Start, r.Stop = bounds[0], bounds[1]
+	case 3:
+		r.Start, r.Stop, r.Step = bounds[0], bounds[1], bounds[2]
+	}
+	if r.Step == 0 {
+		return newError(object.VALUE_ERROR, call, "range step cannot be zero")
+	}
+	if r.Len() < 0 {
+		return newError(object.VALUE_ERROR, call, "range too long: more than %d integers", int64(math.MaxInt64))
+	}
+	return r
+}
+
+// Convert a float, by truncating towards zero, or a string to integer.
+func builtinInt(call token.Token, args ...object.Object) object.Object {
+	if err := checkArgCount("int", call, args, 1); err != nil {
+		return err
+	}
+	switch arg := args[0].(type) {
+	case *object.Integer:
+		return arg
+	case *object.Float:
+		if math.IsNaN(arg.Value) || math.IsInf(arg.Value, 0) ||
+			arg.Value >= math.MaxInt64 || arg.Value < math.MinInt64 {
+			return newError(object.VALUE_ERROR, call, "cannot convert %s to INTEGER", arg.Inspect())
+		}
+		return &object.Integer{Value: int64(arg.Value)}
+	case *object.String:
+		value, err := strconv.ParseInt(strings.TrimSpace(arg.Value), 10, 64)
+		if err != nil {
+			return newError(object.VALUE_ERROR, call, "invalid integer: %q", arg.Value)
+		}
+		return &object.Integer{Value: value}
+	default:
+		return argumentTypeError("int", call, arg)
+	}
+}
+
+// Convert an integer or a string to float.
+func builtinFloat(call token.Token, args ...object.Object) object.Object {
+	if err := checkArgCount("float", call, args, 1); err != nil {
+		return err
+	}
+	switch arg := args[0].(type) {
+	case *object.Integer:
+		return &object.Float{Value: float64(arg.Value)}
+	case *object.Float:
+		return arg
+	case *object.String:
+		value, err := strconv.ParseFloat(strings.TrimSpace(arg.Value), 64)
+		if err != nil {
+			return newError(object.VALUE_ERROR, call, "invalid float: %q", arg.Value)
+		}
+		return &object.Float{Value: value}
+	default:
+		return argumentTypeError("float", call, arg)
+	}
+}
+
+func builtinSplit(call token.Token, args ...object.Object) object.Object {
+	if err := checkArgCount("split", call, args, 2); err != nil {
+		return err
+	}
+	values, err := stringArgs("split", call, args)
+	if err != nil {
+		return err
+	}
+	parts := strings.Split(values[0], values[1])
+	elements := make([]object.Object, len(parts))
+	for i, part := range parts {
+		elements[i] = &object.String{Value: part}
+	}
+	return &object.Array{Elements: elements}
+}
+
+func builtinJoin(call token.Token, args ...object.Object) object.Object {
+	if err := checkArgCount("join", call, args, 2); err != nil {
+		return err
+	}
+	array, ok := args[0].(*object.Array)
+	if !ok {
+		return argumentTypeError("join", call, args[0])
+	}
+	sep, ok := args[1].(*object.String)
+	if !ok {
+		return argumentTypeError("join", call, args[1])
+	}
+	parts, err := stringArgs("join", call, array.Elements)
+	if err != nil {
+		return err
+	}
+	return &object.String{Value: strings.Join(parts, sep.Value)}
+}
+
+func builtinUpper(call token.Token, args ...object.Object) object.Object {
+	if err := checkArgCount("upper", call, args, 1); err != nil {
+		return err
+	}
+	values, err := stringArgs("upper", call, args)
+	if err != nil {
+		return err
+	}
+	return &object.String{Value: strings.ToUpper(values[0])}
+}
+
+func builtinLower(call token.Token, args ...object.Object) object.Object {
+	if err := checkArgCount("lower", call, args, 1); err != nil {
+		return err
+	}
+	values, err := stringArgs("lower", call, args)
+	if err != nil {
+		return err
+	}
+	return &object.String{Value: strings.ToLower(values[0])}
+}
+
+// Remove leading and trailing white space.
+func builtinTrim(call token.Token, args ...object.Object) object.Object {
+	if err := checkArgCount("trim", call, args, 1); err != nil {
+		return err
+	}
+	values, err := stringArgs("trim", call, args)
+	if err != nil {
+		return err
+	}
+	return &object.String{Value: strings.TrimSpace(values[0])}
+}
+
+func builtinContains(call token.Token, args ...object.Object) object.Object {
+	if err := checkArgCount("contains", call, args, 2); err != nil {
+		return err
+	}
+	values, err := stringArgs("contains", call, args)
+	if err != nil {
+		return err
+	}
+	return nativeBoolToBooleanObject(strings.Contains(values[0], values[1]))
+}
+
+// Replace every occurrence of old with new.
+func builtinReplace(call token.Token, args ...object.Object) object.Object {
+	if err := checkArgCount("replace", call, args, 3); err != nil {
+		return err
+	}
+	values, err := stringArgs("replace", call, args)
+	if err != nil {
+		return err
+	}
+	return &object.String{Value: strings.ReplaceAll(values[0], values[1], values[2])}
+}
+
+// Replace each {} in the format string with the next argument, e.g.
+// format("{} + {}", 1, 2) returns "1 + 2".
+func builtinFormat(call token.Token, args ...object.Object) object.Object {
+	if err := checkMinArgCount("format", call, args, 1); err != nil {
+		return err
+	}
+	format, ok := args[0].(*object.String)
+	if !ok {
+		return argumentTypeError("format", call, args[0])
+	}
+	values := args[1:]
+	parts := strings.Split(format.Value, "{}")
+	if len(parts)-1 != len(values) {
+		return newError(object.ARGUMENT_ERROR, call,
+			"wrong number of arguments to format: format string has %d placeholders, got=%d",
+			len(parts)-1, len(values))
+	}
+	var out strings.Builder
+	for i, part := range parts {
+		out.WriteString(part)
+		if i < len(values) {
+			out.WriteString(values[i].Inspect())
+		}
+	}
+	return &object.String{Value: out.String()}
+}
@@ -1,32 +1,978 @@
 package evaluator
 
 import (
+	"fmt"
 	"github.com/px86/monkey/ast"
 	"github.com/px86/monkey/object"
+	"github.com/px86/monkey/token"
+	"iter"
+	"math"
+	"strings"
+	"unicode/utf8"
 )
 
-func Eval(node ast.Node) object.Object {
+// There is only ever one true, one false and one null value, so
+// we reuse them instead of allocating new objects every time.
+var (
+	TRUE  = &object.Boolean{Value: true}
+	FALSE = &object.Boolean{Value: false}
+	NULL  = &object.Null{}
+)
+
+func Eval(node ast.Node, env *object.Environment) object.Object {
 	switch node := node.(type) {
 
 	case *ast.Program:
-		return evalStatements(node.Statements)
+		return evalProgram(node, env)
 
 	case *ast.ExpressionStatement:
-		return Eval(node.Expression)
+		return Eval(node.Expression, env)
+
+	case *ast.BlockStatement:
+		return evalStatements(node.Statements, object.NewEnclosedEnvironment(env))
+
+	case *ast.LetStatement:
+		val := Eval(node.Value, env)
+		if unwinds(val) {
+			return val
+		}
+		if fn, ok := val.(*object.Function); ok && fn.Name == "" {
+			fn.Name = node.Name.Value
+		}
+		env.Set(node.Name.Value, val)
+
+	case *ast.ReturnStatement:
+		val := Eval(node.ReturnValue, env)
+		if unwinds(val) {
+			return val
+		}
+		return &object.ReturnValue{Value: val}
+
+	case *ast.WhileStatement:
+		return evalWhileStatement(node, env)
+
+	case *ast.ForStatement:
+		return evalForStatement(node, env)
+
+	case *ast.ForInStatement:
+		return evalForInStatement(node, env)
+
+	case *ast.BranchStatement:
+		if node.Token.Type == token.KW_BREAK {
+			return &object.Break{Label: labelName(node.Label)}
+		}
+		return &object.Continue{Label: labelName(node.Label)}
+
+	case *ast.Identifier:
+		return evalIdentifier(node, env)
 
 	case *ast.IntegerLiteral:
 		return &object.Integer{Value: node.Value}
+
+	case *ast.FloatLiteral:
+		return &object.Float{Value: node.Value}
+
+	case *ast.StringLiteral:
+		return &object.String{Value: node.Value}
+
+	case *ast.InterpolatedString:
+		return evalInterpolatedString(node, env)
+
+	case *ast.Boolean:
+		return nativeBoolToBooleanObject(node.Value)
+
+	case *ast.GroupedExpr:
+		return Eval(node.Expression, env)
+
+	case *ast.PrefixExpr:
+		right := Eval(node.Expression, env)
+		if unwinds(right) {
+			return right
+		}
+		return evalPrefixExpression(node.Operator, right)
+
+	case *ast.InfixExpr:
+		if node.Operator.Type == token.AMPERSAND_AMPERSAND || node.Operator.Type == token.PIPE_PIPE {
+			return evalLogicalExpression(node, env)
+		}
+		left := Eval(node.Left, env)
+		if unwinds(left) {
+			return left
+		}
+		right := Eval(node.Right, env)
+		if unwinds(right) {
+			return right
+		}
+		return evalInfixExpression(node.Operator, left, right)
+
+	case *ast.IfExpression:
+		return evalIfExpression(node, env)
+
+	case *ast.FunctionExpr:
+		return &object.Function{Parameters: node.Args, Body: node.Body, Env: env}
+
+	case *ast.FunctionCall:
+		function := Eval(node.Function, env)
+		if unwinds(function) {
+			return function
+		}
+		args := evalExpressions(node.Args, env)
+		if len(args) == 1 && unwinds(args[0]) {
+			return args[0]
+		}
+		return applyFunction(node, function, args)
+
+	case *ast.ArrayLiteral:
+		elements := evalExpressions(node.Elements, env)
+		if len(elements) == 1 && unwinds(elements[0]) {
+			return elements[0]
+		}
+		return &object.Array{Elements: elements}
+
+	case *ast.SliceExpr:
+		return evalSliceExpression(node, env)
+
+	case *ast.HashLiteral:
+		return evalHashLiteral(node, env)
+
+	case *ast.AssignExpr:
+		return evalAssignExpression(node, env)
+
+	case *ast.IndexExpr:
+		left := Eval(node.Left, env)
+		if unwinds(left) {
+			return left
+		}
+		index := Eval(node.Index, env)
+		if unwinds(index) {
+			return index
+		}
+		return evalIndexExpression(node.Token, left, index)
 	}
 
 	return nil
 }
 
+// Same as evalStatements, except that a return value is unwrapped
+// since there is nothing left to unwind.
+func evalProgram(program *ast.Program, env *object.Environment) object.Object {
+	result := evalStatements(program.Statements, env)
+	if rv, ok := result.(*object.ReturnValue); ok {
+		return rv.Value
+	}
+	return result
+}
+
 // The evaluation of a list of statements returns the value of the
-// evaluation of the last expression.
-func evalStatements(stmts []ast.Statement) object.Object {
+// evaluation of the last expression. An error, a return value, a break
+// or a continue stops the evaluation and is passed on as is.
+func evalStatements(stmts []ast.Statement, env *object.Environment) object.Object {
 	var result object.Object
 	for _, stmt := range stmts {
-		result = Eval(stmt)
+		result = Eval(stmt, env)
+		if unwinds(result) {
+			return result
+		}
+	}
+	return result
+}
+
+// Evaluate exprs from left to right. If any of them unwinds, a slice
+// containing only its value is returned.
+func evalExpressions(exprs []ast.Expression, env *object.Environment) []object.Object {
+	result := []object.Object{}
+	for _, e := range exprs {
+		evaluated := Eval(e, env)
+		if evaluated == nil {
+			evaluated = NULL
+		}
+		if unwinds(evaluated) {
+			return []object.Object{evaluated}
+		}
+		result = append(result, evaluated)
+	}
+	return result
+}
+
+func applyFunction(call *ast.FunctionCall, fn object.Object, args []object.Object) object.Object {
+	callee := startToken(call.Function, call.Token)
+	if builtin, ok := fn.(*object.Builtin); ok {
+		return builtin.Fn(callee, args...)
+	}
+	if fn == nil {
+		fn = NULL
+	}
+	function, ok := fn.(*object.Function)
+	if !ok {
+		return newError(object.TYPE_ERROR, callee, "not a function: %s", fn.Type())
+	}
+	if len(args) != len(function.Parameters) {
+		return newError(object.ARGUMENT_ERROR, callee, "wrong number of arguments to %s: expected=%d, got=%d",
+			functionName(function), len(function.Parameters), len(args))
+	}
+
+	env := object.NewEnclosedEnvironment(function.Env)
+	for i, param := range function.Parameters {
+		env.Set(param.Value, args[i])
+	}
+
+	result := Eval(function.Body, env)
+	if errobj, ok := result.(*object.Error); ok {
+		errobj.Stack = append(errobj.Stack, object.Frame{
+			Function: functionName(function),
+			CallSite: callee,
+		})
+		return errobj
+	}
+	if rv, ok := result.(*object.ReturnValue); ok {
+		return rv.Value
+	}
+	if result == nil {
+		return NULL
 	}
 	return result
 }
+
+// Return the first token of expr, at which errors about expr are
+// reported: for a call, the name of the called function rather than the
+// ( of its arguments. Nodes without a token of their own yield def.
+func startToken(expr ast.Expression, def token.Token) token.Token {
+	for {
+		switch e := expr.(type) {
+		case *ast.Identifier:
+			return e.Token
+		case *ast.IntegerLiteral:
+			return e.Token
+		case *ast.FloatLiteral:
+			return e.Token
+		case *ast.StringLiteral:
+			return e.Token
+		case *ast.InterpolatedString:
+			return e.Token
+		case *ast.Boolean:
+			return e.Token
+		case *ast.ArrayLiteral:
+			return e.Token
+		case *ast.HashLiteral:
+			return e.Token
+		case *ast.FunctionExpr:
+			return e.Token
+		case *ast.IfExpression:
+			return e.Token
+		case *ast.PrefixExpr:
+			return e.Operator
+		case *ast.GroupedExpr:
+			return e.Lparen
+		case *ast.FunctionCall:
+			expr = e.Function
+		case *ast.IndexExpr:
+			expr = e.Left
+		case *ast.SliceExpr:
+			expr = e.Left
+		case *ast.InfixExpr:
+			expr = e.Left
+		case *ast.AssignExpr:
+			expr = e.Target
+		default:
+			return def
+		}
+	}
+}
+
+func functionName(fn *object.Function) string {
+	if fn.Name == "" {
+		return "<anonymous>"
+	}
+	return fn.Name
+}
+
+func evalIdentifier(ident *ast.Identifier, env *object.Environment) object.Object {
+	val, ok := env.Get(ident.Value)
+	if ok {
+		return val
+	}
+	if builtin, ok := builtins[ident.Value]; ok {
+		return builtin
+	}
+	return newError(object.NAME_ERROR, ident.Token, "identifier not found: %s", ident.Value)
+}
+
+func newError(kind object.ErrorKind, tok token.Token, format string, a ...any) *object.Error {
+	return &object.Error{
+		Kind:    kind,
+		Message: fmt.Sprintf(format, a...),
+		Token:   tok,
+	}
+}
+
+func isError(obj object.Object) bool {
+	if obj != nil {
+		return obj.Type() == object.ERROR_OBJ
+	}
+	return false
+}
+
+// Report whether obj stops the evaluation of the enclosing expressions and
+// is passed on as is, up to the statement that handles it: an error, a
+// return value to be unwrapped by the function call, or a break or
+// continue for the enclosing loop.
+func unwinds(obj object.Object) bool {
+	if obj != nil {
+		switch obj.Type() {
+		case object.ERROR_OBJ, object.RETURN_VALUE_OBJ, object.BREAK_OBJ, object.CONTINUE_OBJ:
+			return true
+		}
+	}
+	return false
+}
+
+func nativeBoolToBooleanObject(b bool) *object.Boolean {
+	if b {
+		return TRUE
+	}
+	return FALSE
+}
+
+// Everything except false and null is truthy, including 0 and "".
+func isTruthy(obj object.Object) bool {
+	switch obj {
+	case NULL, FALSE, nil:
+		return false
+	default:
+		return true
+	}
+}
+
+func evalPrefixExpression(operator token.Token, right object.Object) object.Object {
+	if right == nil {
+		right = NULL
+	}
+	switch operator.Type {
+	case token.EXCLAMATION:
+		return nativeBoolToBooleanObject(!isTruthy(right))
+	case token.MINUS:
+		switch right := right.(type) {
+		case *object.Integer:
+			return &object.Integer{Value: -right.Value}
+		case *object.Float:
+			return &object.Float{Value: -right.Value}
+		default:
+			return newError(object.TYPE_ERROR, operator, "unknown operator: %s%s",
+				token.AsString(operator.Type), right.Type())
+		}
+	case token.TILDE:
+		if right, ok := right.(*object.Integer); ok {
+			return &object.Integer{Value: ^right.Value}
+		}
+		return newError(object.TYPE_ERROR, operator, "unknown operator: %s%s",
+			token.AsString(operator.Type), right.Type())
+	default:
+		return newError(object.TYPE_ERROR, operator, "unknown operator: %s%s",
+			token.AsString(operator.Type), right.Type())
+	}
+}
+
+// && and || evaluate their right operand only if the left one does not
+// decide the result, which is a boolean.
+func evalLogicalExpression(node *ast.InfixExpr, env *object.Environment) object.Object {
+	left := Eval(node.Left, env)
+	if unwinds(left) {
+		return left
+	}
+	if isTruthy(left) == (node.Operator.Type == token.PIPE_PIPE) {
+		return nativeBoolToBooleanObject(isTruthy(left))
+	}
+	right := Eval(node.Right, env)
+	if unwinds(right) {
+		return right
+	}
+	return nativeBoolToBooleanObject(isTruthy(right))
+}
+
+func evalInfixExpression(operator token.Token, left, right object.Object) object.Object {
+	if left == nil {
+		left = NULL
+	}
+	if right == nil {
+		right = NULL
+	}
+	switch {
+	case left.Type() == object.INTEGER_OBJ && right.Type() == object.INTEGER_OBJ:
+		return evalIntegerInfixExpression(operator, left.(*object.Integer), right.(*object.Integer))
+	// an integer operand is promoted to float if the other one is a float
+	case isNumber(left) && isNumber(right):
+		return evalFloatInfixExpression(operator, toFloat(left), toFloat(right))
+	case left.Type() == object.STRING_OBJ && right.Type() == object.STRING_OBJ:
+		return evalStringInfixExpression(operator, left.(*object.String), right.(*object.String))
+	// booleans and null are singletons, so comparing pointers is enough
+	case operator.Type == token.EQUAL_EQUAL:
+		return nativeBoolToBooleanObject(left == right)
+	case operator.Type == token.EXCLAMATION_EQUAL:
+		return nativeBoolToBooleanObject(left != right)
+	case left.Type() != right.Type():
+		return newError(object.TYPE_ERROR, operator, "type mismatch: %s %s %s",
+			left.Type(), token.AsString(operator.Type), right.Type())
+	default:
+		return newError(object.TYPE_ERROR, operator, "unknown operator: %s %s %s",
+			left.Type(), token.AsString(operator.Type), right.Type())
+	}
+}
+
+func evalIntegerInfixExpression(operator token.Token, left, right *object.Integer) object.Object {
+	l, r := left.Value, right.Value
+	switch operator.Type {
+	case token.PLUS:
+		return &object.Integer{Value: l + r}
+	case token.MINUS:
+		return &object.Integer{Value: l - r}
+	case token.ASTERISK:
+		return &object.Integer{Value: l * r}
+	case token.SLASH:
+		if r == 0 {
+			return newError(object.ZERO_DIVISION, operator, "division by zero")
+		}
+		return &object.Integer{Value: l / r}
+	case token.PERCENT:
+		if r == 0 {
+			return newError(object.ZERO_DIVISION, operator, "modulo by zero")
+		}
+		return &object.Integer{Value: l % r}
+	case token.ASTERISK_ASTERISK:
+		// a negative power is a fraction
+		if r < 0 {
+			return &object.Float{Value: math.Pow(float64(l), float64(r))}
+		}
+		return &object.Integer{Value: integerPower(l, r)}
+	case token.AMPERSAND:
+		return &object.Integer{Value: l & r}
+	case token.PIPE:
+		return &object.Integer{Value: l | r}
+	case token.CARET:
+		return &object.Integer{Value: l ^ r}
+	case token.LESSER_LESSER, token.GREATER_GREATER:
+		if r < 0 {
+			return newError(object.VALUE_ERROR, operator, "negative shift count: %d", r)
+		}
+		if operator.Type == token.LESSER_LESSER {
+			return &object.Integer{Value: l << r}
+		}
+		return &object.Integer{Value: l >> r}
+	case token.LESSER_THAN:
+		return nativeBoolToBooleanObject(l < r)
+	case token.LESSER_THAN_EQUAL:
+		return nativeBoolToBooleanObject(l <= r)
+	case token.GREATER_THAN:
+		return nativeBoolToBooleanObject(l > r)
+	case token.GREATER_THAN_EQUAL:
+		return nativeBoolToBooleanObject(l >= r)
+	case token.EQUAL_EQUAL:
+		return nativeBoolToBooleanObject(l == r)
+	case token.EXCLAMATION_EQUAL:
+		return nativeBoolToBooleanObject(l != r)
+	default:
+		return newError(object.TYPE_ERROR, operator, "unknown operator: %s %s %s",
+			left.Type(), token.AsString(operator.Type), right.Type())
+	}
+}
+
+func evalFloatInfixExpression(operator token.Token, l, r float64) object.Object {
+	switch operator.Type {
+	case token.PLUS:
+		return &object.Float{Value: l + r}
+	case token.MINUS:
+		return &object.Float{Value: l - r}
+	case token.ASTERISK:
+		return &object.Float{Value: l * r}
+	case token.SLASH:
+		if r == 0 {
+			return newError(object.ZERO_DIVISION, operator, "division by zero")
+		}
+		return &object.Float{Value: l / r}
+	case token.PERCENT:
+		if r == 0 {
+			return newError(object.ZERO_DIVISION, operator, "modulo by zero")
+		}
+		return &object.Float{Value: math.Mod(l, r)}
+	case token.ASTERISK_ASTERISK:
+		return &object.Float{Value: math.Pow(l, r)}
+	case token.LESSER_THAN:
+		return nativeBoolToBooleanObject(l < r)
+	case token.LESSER_THAN_EQUAL:
+		return nativeBoolToBooleanObject(l <= r)
+	case token.GREATER_THAN:
+		return nativeBoolToBooleanObject(l > r)
+	case token.GREATER_THAN_EQUAL:
+		return nativeBoolToBooleanObject(l >= r)
+	case token.EQUAL_EQUAL:
+		return nativeBoolToBooleanObject(l == r)
+	case token.EXCLAMATION_EQUAL:
+		return nativeBoolToBooleanObject(l != r)
+	default:
+		return newError(object.TYPE_ERROR, operator, "unknown operator: %s %s %s",
+			object.FLOAT_OBJ, token.AsString(operator.Type), object.FLOAT_OBJ)
+	}
+}
+
+// Compute base to the power of exp, which is not negative, by repeated
+// squaring. Like the other integer operations, it wraps around on overflow.
+func integerPower(base, exp int64) int64 {
+	result := int64(1)
+	for ; exp > 0; exp >>= 1 {
+		if exp&1 == 1 {
+			result *= base
+		}
+		base *= base
+	}
+	return result
+}
+
+func isNumber(obj object.Object) bool {
+	switch obj.(type) {
+	case *object.Integer, *object.Float:
+		return true
+	default:
+		return false
+	}
+}
+
+// Convert an integer or a float to float64. Callers must check isNumber first.
+func toFloat(obj object.Object) float64 {
+	if integer, ok := obj.(*object.Integer); ok {
+		return float64(integer.Value)
+	}
+	return obj.(*object.Float).Value
+}
+
+func evalStringInfixExpression(operator token.Token, left, right *object.String) object.Object {
+	switch operator.Type {
+	case token.PLUS:
+		return &object.String{Value: left.Value + right.Value}
+	case token.EQUAL_EQUAL:
+		return nativeBoolToBooleanObject(left.Value == right.Value)
+	case token.EXCLAMATION_EQUAL:
+		return nativeBoolToBooleanObject(left.Value != right.Value)
+	case token.LESSER_THAN:
+		return nativeBoolToBooleanObject(left.Value < right.Value)
+	case token.LESSER_THAN_EQUAL:
+		return nativeBoolToBooleanObject(left.Value <= right.Value)
+	case token.GREATER_THAN:
+		return nativeBoolToBooleanObject(left.Value > right.Value)
+	case token.GREATER_THAN_EQUAL:
+		return nativeBoolToBooleanObject(left.Value >= right.Value)
+	default:
+		return newError(object.TYPE_ERROR, operator, "unknown operator: %s %s %s",
+			left.Type(), token.AsString(operator.Type), right.Type())
+	}
+}
+
+func evalIndexExpression(tok token.Token, left, index object.Object) object.Object {
+	switch left := left.(type) {
+	case *object.Array:
+		i, ok := index.(*object.Integer)
+		if !ok {
+			return newError(object.TYPE_ERROR, tok, "array index must be INTEGER, got=%s", typeOf(index))
+		}
+		return evalArrayIndexExpression(tok, left, i.Value)
+	case *object.String:
+		i, ok := index.(*object.Integer)
+		if !ok {
+			return newError(object.TYPE_ERROR, tok, "string index must be INTEGER, got=%s", typeOf(index))
+		}
+		return evalStringIndexExpression(tok, left, i.Value)
+	case *object.Hash:
+		key, ok := index.(object.Hashable)
+		if !ok {
+			return newError(object.TYPE_ERROR, tok, "unusable as hash key: %s", typeOf(index))
+		}
+		value, ok := left.Get(key)
+		if !ok {
+			return NULL
+		}
+		return value
+	default:
+		return newError(object.TYPE_ERROR, tok, "index operator not supported: %s", typeOf(left))
+	}
+}
+
+// The binary operator applied by each compound assignment.
+var compoundOperators = map[token.TokenType]token.TokenType{
+	token.PLUS_EQUAL:     token.PLUS,
+	token.MINUS_EQUAL:    token.MINUS,
+	token.ASTERISK_EQUAL: token.ASTERISK,
+	token.SLASH_EQUAL:    token.SLASH,
+}
+
+// Assign to a variable, or to an element of an array or a hash. The value
+// of the assignment is the assigned value.
+func evalAssignExpression(node *ast.AssignExpr, env *object.Environment) object.Object {
+	targetExpr := ast.Unparen(node.Target)
+	// the container and the index are evaluated before the value
+	var container, index object.Object
+	if target, ok := targetExpr.(*ast.IndexExpr); ok {
+		container = Eval(target.Left, env)
+		if unwinds(container) {
+			return container
+		}
+		index = Eval(target.Index, env)
+		if unwinds(index) {
+			return index
+		}
+	}
+
+	val := Eval(node.Value, env)
+	if unwinds(val) {
+		return val
+	}
+	if val == nil {
+		val = NULL
+	}
+
+	if operator, ok := compoundOperators[node.Token.Type]; ok {
+		var current object.Object
+		if ident, ok := targetExpr.(*ast.Identifier); ok {
+			current = evalIdentifier(ident, env)
+		} else {
+			current = evalIndexExpression(targetExpr.(*ast.IndexExpr).Token, container, index)
+		}
+		if isError(current) {
+			return current
+		}
+		tok := node.Token
+		tok.Type = operator
+		val = evalInfixExpression(tok, current, val)
+		if isError(val) {
+			return val
+		}
+	}
+
+	switch target := targetExpr.(type) {
+	case *ast.Identifier:
+		if !env.Assign(target.Value, val) {
+			return newError(object.NAME_ERROR, target.Token,
+				"cannot assign to undeclared identifier: %s", target.Value)
+		}
+	case *ast.IndexExpr:
+		if err := evalIndexAssignment(target.Token, container, index, val); err != nil {
+			return err
+		}
+	}
+	return val
+}
+
+// Set the element of container at index to val, in place.
+func evalIndexAssignment(tok token.Token, container, index, val object.Object) *object.Error {
+	switch container := container.(type) {
+	case *object.Array:
+		i, ok := index.(*object.Integer)
+		if !ok {
+			return newError(object.TYPE_ERROR, tok, "array index must be INTEGER, got=%s", typeOf(index))
+		}
+		length := int64(len(container.Elements))
+		position := i.Value
+		if position < 0 {
+			position += length
+		}
+		if position < 0 || position >= length {
+			return newError(object.INDEX_ERROR, tok, "array index out of range: %d (length %d)", i.Value, length)
+		}
+		container.Elements[position] = val
+	case *object.Hash:
+		key, ok := index.(object.Hashable)
+		if !ok {
+			return newError(object.TYPE_ERROR, tok, "unusable as hash key: %s", typeOf(index))
+		}
+		container.Set(key, val)
+	default:
+		return newError(object.TYPE_ERROR, tok, "index assignment not supported: %s", typeOf(container))
+	}
+	return nil
+}
+
+func evalHashLiteral(node *ast.HashLiteral, env *object.Environment) object.Object {
+	hash := object.NewHash()
+	for _, pair := range node.Pairs {
+		key := Eval(pair.Key, env)
+		if unwinds(key) {
+			return key
+		}
+		hashable, ok := key.(object.Hashable)
+		if !ok {
+			return newError(object.TYPE_ERROR, startToken(pair.Key, node.Token),
+				"unusable as hash key: %s", typeOf(key))
+		}
+		value := Eval(pair.Value, env)
+		if unwinds(value) {
+			return value
+		}
+		if value == nil {
+			value = NULL
+		}
+		hash.Set(hashable, value)
+	}
+	return hash
+}
+
+// Negative indices count from the end of the array, so a[-1] is the
+// last element.
+func evalArrayIndexExpression(tok token.Token, array *object.Array, index int64) object.Object {
+	length := int64(len(array.Elements))
+	i := index
+	if i < 0 {
+		i += length
+	}
+	if i < 0 || i >= length {
+		return newError(object.INDEX_ERROR, tok, "array index out of range: %d (length %d)", index, length)
+	}
+	return array.Elements[i]
+}
+
+// Strings are indexed by rune, not by byte, so s[i] is the i-th
+// character of s as a string.
+func evalStringIndexExpression(tok token.Token, str *object.String, index int64) object.Object {
+	runes := []rune(str.Value)
+	length := int64(len(runes))
+	i := index
+	if i < 0 {
+		i += length
+	}
+	if i < 0 || i >= length {
+		return newError(object.INDEX_ERROR, tok, "string index out of range: %d (length %d)", index, length)
+	}
+	return &object.String{Value: string(runes[i])}
+}
+
+// Slicing an array or a string returns a new value. As with indexing,
+// negative bounds count from the end; bounds past either end are clamped.
+func evalSliceExpression(node *ast.SliceExpr, env *object.Environment) object.Object {
+	left := Eval(node.Left, env)
+	if unwinds(left) {
+		return left
+	}
+
+	var length int64
+	switch left := left.(type) {
+	case *object.Array:
+		length = int64(len(left.Elements))
+	case *object.String:
+		length = int64(utf8.RuneCountInString(left.Value))
+	default:
+		return newError(object.TYPE_ERROR, node.Token, "slice operator not supported: %s", typeOf(left))
+	}
+
+	low, err := evalSliceBound(node.Token, node.Low, 0, length, env)
+	if err != nil {
+		return err
+	}
+	high, err := evalSliceBound(node.Token, node.High, length, length, env)
+	if err != nil {
+		return err
+	}
+	if high < low {
+		high = low
+	}
+
+	switch left := left.(type) {
+	case *object.Array:
+		elements := make([]object.Object, high-low)
+		copy(elements, left.Elements[low:high])
+		return &object.Array{Elements: elements}
+	default:
+		runes := []rune(left.(*object.String).Value)
+		return &object.String{Value: string(runes[low:high])}
+	}
+}
+
+func evalSliceBound(tok token.Token, bound ast.Expression, def, length int64, env *object.Environment) (int64, object.Object) {
+	if bound == nil {
+		return def, nil
+	}
+	obj := Eval(bound, env)
+	if unwinds(obj) {
+		return 0, obj
+	}
+	integer, ok := obj.(*object.Integer)
+	if !ok {
+		return 0, newError(object.TYPE_ERROR, tok, "slice bound must be INTEGER, got=%s", typeOf(obj))
+	}
+	i := integer.Value
+	if i < 0 {
+		i += length
+	}
+	return max(0, min(i, length)), nil
+}
+
+func typeOf(obj object.Object) object.ObjectType {
+	if obj == nil {
+		return object.NULL_OBJ
+	}
+	return obj.Type()
+}
+
+// Concatenate the parts of the string. Parts that are not strings are
+// converted with Inspect.
+func evalInterpolatedString(node *ast.InterpolatedString, env *object.Environment) object.Object {
+	var out strings.Builder
+	for _, part := range node.Parts {
+		obj := Eval(part, env)
+		if unwinds(obj) {
+			return obj
+		}
+		if obj == nil {
+			obj = NULL
+		}
+		out.WriteString(obj.Inspect())
+	}
+	return &object.String{Value: out.String()}
+}
+
+func evalIfExpression(ie *ast.IfExpression, env *object.Environment) object.Object {
+	condition := Eval(ie.Condition, env)
+	if unwinds(condition) {
+		return condition
+	}
+	if isTruthy(condition) {
+		return Eval(ie.ThenBlock, env)
+	} else if ie.ElseBlock != nil {
+		return Eval(ie.ElseBlock, env)
+	}
+	return NULL
+}
+
+// Loops evaluate to null. They are evaluated iteratively, so unlike
+// recursion they do not grow the Go stack.
+func evalWhileStatement(ws *ast.WhileStatement, env *object.Environment) object.Object {
+	for {
+		condition := Eval(ws.Condition, env)
+		if unwinds(condition) {
+			return condition
+		}
+		if !isTruthy(condition) {
+			return NULL
+		}
+		if done, result := loopControl(labelName(ws.Label), Eval(ws.Body, env)); done {
+			return result
+		}
+	}
+}
+
+// The variables declared by the init statement are local to the loop.
+func evalForStatement(fs *ast.ForStatement, env *object.Environment) object.Object {
+	env = object.NewEnclosedEnvironment(env)
+	if fs.Init != nil {
+		if init := Eval(fs.Init, env); unwinds(init) {
+			return init
+		}
+	}
+	for {
+		if fs.Condition != nil {
+			condition := Eval(fs.Condition, env)
+			if unwinds(condition) {
+				return condition
+			}
+			if !isTruthy(condition) {
+				return NULL
+			}
+		}
+		if done, result := loopControl(labelName(fs.Label), Eval(fs.Body, env)); done {
+			return result
+		}
+		if fs.Update != nil {
+			if update := Eval(fs.Update, env); unwinds(update) {
+				return update
+			}
+		}
+	}
+}
+
+// Each iteration binds the loop variable in an environment of its own, so
+// that closures created by the body capture the value of their iteration.
+func evalForInStatement(fs *ast.ForInStatement, env *object.Environment) object.Object {
+	iterable := Eval(fs.Iterable, env)
+	if unwinds(iterable) {
+		return iterable
+	}
+	items := iterate(iterable)
+	if items == nil {
+		return newError(object.TYPE_ERROR, fs.Token, "cannot iterate over %s", typeOf(iterable))
+	}
+	for item := range items {
+		iterEnv := object.NewEnclosedEnvironment(env)
+		iterEnv.Set(fs.Variable.Value, item)
+		if done, result := loopControl(labelName(fs.Label), Eval(fs.Body, iterEnv)); done {
+			return result
+		}
+	}
+	return NULL
+}
+
+// Return the items of a for-in loop over obj: the elements of an array,
+// the characters of a string, the keys of a hash in insertion order, or
+// the integers of a range. It is nil if obj cannot be iterated over.
+func iterate(obj object.Object) iter.Seq[object.Object] {
+	switch obj := obj.(type) {
+	case *object.Array:
+		return func(yield func(object.Object) bool) {
+			for _, el := range obj.Elements {
+				if !yield(el) {
+					return
+				}
+			}
+		}
+	case *object.String:
+		return func(yield func(object.Object) bool) {
+			for _, c := range obj.Value {
+				if !yield(&object.String{Value: string(c)}) {
+					return
+				}
+			}
+		}
+	case *object.Hash:
+		return func(yield func(object.Object) bool) {
+			for _, pair := range obj.Pairs() {
+				if !yield(pair.Key) {
+					return
+				}
+			}
+		}
+	case *object.Range:
+		return func(yield func(object.Object) bool) {
+			for i := range obj.Len() {
+				if !yield(&object.Integer{Value: obj.At(i)}) {
+					return
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// Return the name of a loop label, or "" if there is none.
+func labelName(label *ast.Identifier) string {
+	if label == nil {
+		return ""
+	}
+	return label.Value
+}
+
+// Interpret the result of running the body of the loop with the given
+// label once. done reports whether the loop stops, and result is then
+// the value of the loop: null, or what is passed on to the enclosing
+// statements, i.e. an error, a return value, or a break or continue
+// meant for an outer loop.
+func loopControl(label string, body object.Object) (done bool, result object.Object) {
+	switch body := body.(type) {
+	case *object.Break:
+		if body.Label == "" || body.Label == label {
+			return true, NULL
+		}
+		return true, body
+	case *object.Continue:
+		if body.Label == "" || body.Label == label {
+			return false, nil
+		}
+		return true, body
+	case *object.ReturnValue, *object.Error:
+		return true, body
+	}
+	return false, nil
+}
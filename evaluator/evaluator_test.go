@@ -4,6 +4,7 @@ import (
 	"github.com/px86/monkey/lexer"
 	"github.com/px86/monkey/object"
 	"github.com/px86/monkey/parser"
+	"strings"
 	"testing"
 )
 
@@ -15,6 +16,30 @@ func TestEvalIntegerExpression(t *testing.T) {
 		{"5", 5},
 		{"101", 101},
 		{"1721", 1721},
+		{"-5", -5},
+		{"--5", 5},
+		{"5 + 5 + 5 + 5 - 10", 10},
+		{"2 * 2 * 2 * 2 * 2", 32},
+		{"-50 + 100 + -50", 0},
+		{"5 * 2 + 10", 20},
+		{"5 + 2 * 10", 25},
+		{"50 / 2 * 2 + 10", 60},
+		{"2 * (5 + 10)", 30},
+		{"(5 + 10 * 2 + 15 / 3) * 2 + -10", 50},
+		{"7 % 3", 1},
+		{"-7 % 3", -1},
+		{"2 ** 10", 1024},
+		{"2 ** 3 ** 2", 512},
+		{"-2 ** 2", -4},
+		{"3 ** 0", 1},
+		{"6 & 3", 2},
+		{"6 | 3", 7},
+		{"6 ^ 3", 5},
+		{"~5", -6},
+		{"1 << 4", 16},
+		{"-16 >> 2", -4},
+		{"1 << 2 + 1", 8},
+		{"5 & 3 | 8 ^ 1", 9},
 	}
 
 	for _, testcase := range testcases {
@@ -23,10 +48,848 @@ func TestEvalIntegerExpression(t *testing.T) {
 	}
 }
 
+func TestEvalFloatExpression(t *testing.T) {
+	testcases := []struct {
+		expr     string
+		expected float64
+	}{
+		{"3.14", 3.14},
+		{"-2.5", -2.5},
+		{"1.5 + 1.5", 3},
+		{"1 + 0.5", 1.5},
+		{"0.5 * 4", 2},
+		{"7 / 2.0", 3.5},
+		{"2.5e-3 * 1e3", 2.5},
+		{"7.5 % 2", 1.5},
+		{"2.25 ** 0.5", 1.5},
+		{"2 ** -2", 0.25},
+		{"float(3)", 3},
+		{`float("1.25")`, 1.25},
+	}
+
+	for _, testcase := range testcases {
+		obj := testEval(testcase.expr)
+		fobj, ok := obj.(*object.Float)
+		if !ok {
+			t.Errorf("object is not Float. got=%T (%v)", obj, obj)
+			continue
+		}
+		if fobj.Value != testcase.expected {
+			t.Errorf("object value does not match. got=%g. exprected=%g", fobj.Value, testcase.expected)
+		}
+	}
+}
+
+func TestMixedNumbers(t *testing.T) {
+	testcases := []struct {
+		expr     string
+		expected any
+	}{
+		{"7 / 2", 3},
+		{"int(3.9)", 3},
+		{"int(-3.9)", -3},
+		{`int(" 42 ")`, 42},
+		{"1 == 1.0", true},
+		{"1 < 1.5", true},
+		{"2.5 >= 3", false},
+		{"0.1 + 0.2 != 0.3", true},
+	}
+
+	for _, testcase := range testcases {
+		obj := testEval(testcase.expr)
+		switch expected := testcase.expected.(type) {
+		case int:
+			testIntegerObject(t, obj, int64(expected))
+		case bool:
+			testBooleanObject(t, obj, expected)
+		}
+	}
+}
+
+func TestFloatInspect(t *testing.T) {
+	testcases := []struct {
+		expr     string
+		expected string
+	}{
+		{"2.0", "2.0"},
+		{"0.5", "0.5"},
+		{"1e21 * 10", "1e+22"},
+		{"1 + 1.0", "2.0"},
+	}
+
+	for _, testcase := range testcases {
+		obj := testEval(testcase.expr)
+		if obj.Inspect() != testcase.expected {
+			t.Errorf("wrong Inspect for %s. expected=%q, got=%q", testcase.expr, testcase.expected, obj.Inspect())
+		}
+	}
+}
+
+func TestEvalBooleanExpression(t *testing.T) {
+	testcases := []struct {
+		expr     string
+		expected bool
+	}{
+		{"true", true},
+		{"false", false},
+		{"1 < 2", true},
+		{"1 > 2", false},
+		{"1 <= 1", true},
+		{"2 >= 3", false},
+		{"1 == 1", true},
+		{"1 != 1", false},
+		{"1 + 1 == 2", true},
+		{"true == true", true},
+		{"true != false", true},
+		{"(1 < 2) == true", true},
+		{"(1 > 2) == true", false},
+		{"\"foo\" == \"foo\"", true},
+		{"\"foo\" != \"bar\"", true},
+	}
+
+	for _, testcase := range testcases {
+		obj := testEval(testcase.expr)
+		testBooleanObject(t, obj, testcase.expected)
+	}
+}
+
+func TestLogicalOperators(t *testing.T) {
+	testcases := []struct {
+		expr     string
+		expected bool
+	}{
+		{"true && true", true},
+		{"true && false", false},
+		{"false || true", true},
+		{"false || false", false},
+		{"1 && \"\"", true},
+		{"1 < 2 && 2 < 3", true},
+		{"1 == 2 || 3 != 3", false},
+		// the right operand is not evaluated if the left one decides
+		{"false && undefined", false},
+		{"true || undefined", true},
+		{"let n = 0; let f = fn() { 1 / n }; 1 > 2 && f()", false},
+	}
+
+	for _, testcase := range testcases {
+		obj := testEval(testcase.expr)
+		testBooleanObject(t, obj, testcase.expected)
+	}
+}
+
+func TestBangOperator(t *testing.T) {
+	testcases := []struct {
+		expr     string
+		expected bool
+	}{
+		{"!true", false},
+		{"!false", true},
+		{"!5", false},
+		{"!!true", true},
+		{"!!5", true},
+	}
+
+	for _, testcase := range testcases {
+		obj := testEval(testcase.expr)
+		testBooleanObject(t, obj, testcase.expected)
+	}
+}
+
+func TestIfExpressions(t *testing.T) {
+	testcases := []struct {
+		expr     string
+		expected any
+	}{
+		{"if (true) { 10 }", 10},
+		{"if (false) { 10 }", nil},
+		{"if (1) { 10 }", 10},
+		{"if (1 < 2) { 10 }", 10},
+		{"if (1 > 2) { 10 }", nil},
+		{"if (1 > 2) { 10 } else { 20 }", 20},
+		{"if (1 < 2) { 10 } else { 20 }", 10},
+	}
+
+	for _, testcase := range testcases {
+		obj := testEval(testcase.expr)
+		integer, ok := testcase.expected.(int)
+		if ok {
+			testIntegerObject(t, obj, int64(integer))
+		} else if obj != NULL {
+			t.Errorf("object is not NULL. got=%T (%v)", obj, obj)
+		}
+	}
+}
+
+func TestStringConcatenation(t *testing.T) {
+	obj := testEval(`"Hello" + " " + "World!"`)
+	str, ok := obj.(*object.String)
+	if !ok {
+		t.Fatalf("object is not String. got=%T (%v)", obj, obj)
+	}
+	if str.Value != "Hello World!" {
+		t.Errorf("String has wrong value. got=%q", str.Value)
+	}
+}
+
+func TestLetStatements(t *testing.T) {
+	testcases := []struct {
+		expr     string
+		expected int64
+	}{
+		{"let a = 5; a;", 5},
+		{"let a = 5 * 5; a;", 25},
+		{"let a = 5; let b = a; b;", 5},
+		{"let a = 5; let b = a; let c = a + b + 5; c;", 15},
+		{"let a = 5; if (true) { let a = 10; a }", 10},
+		{"let a = 5; if (true) { let b = a * 2; b }", 10},
+		{"let a = 5; if (true) { let a = 10; } a", 5},
+		{"let größe = 5; let 数 = größe * 2; 数", 10},
+	}
+
+	for _, testcase := range testcases {
+		testIntegerObject(t, testEval(testcase.expr), testcase.expected)
+	}
+}
+
+func TestUnknownIdentifier(t *testing.T) {
+	obj := testEval("let a = 1;\nlet b = a + foo;\nb;")
+	errobj, ok := obj.(*object.Error)
+	if !ok {
+		t.Fatalf("object is not Error. got=%T (%v)", obj, obj)
+	}
+	if errobj.Message != "identifier not found: foo" {
+		t.Errorf("wrong error message. got=%q", errobj.Message)
+	}
+	if errobj.Token.Line != 2 || errobj.Token.Column != 12 {
+		t.Errorf("wrong error position. expected=2:12, got=%d:%d",
+			errobj.Token.Line, errobj.Token.Column)
+	}
+}
+
+func TestReturnStatements(t *testing.T) {
+	testcases := []struct {
+		expr     string
+		expected int64
+	}{
+		{"return 10;", 10},
+		{"return 10; 9;", 10},
+		{"return 2 * 5; 9;", 10},
+		{"9; return 2 * 5; 9;", 10},
+		{"if (10 > 1) { if (10 > 1) { return 10; } return 1; }", 10},
+	}
+
+	for _, testcase := range testcases {
+		testIntegerObject(t, testEval(testcase.expr), testcase.expected)
+	}
+}
+
+func TestFunctionApplication(t *testing.T) {
+	testcases := []struct {
+		expr     string
+		expected int64
+	}{
+		{"let identity = fn(x) { x; }; identity(5);", 5},
+		{"let identity = fn(x) { return x; }; identity(5);", 5},
+		{"let double = fn(x) { x * 2; }; double(5);", 10},
+		{"let add = fn(x, y) { x + y; }; add(5, 5);", 10},
+		{"let add = fn(x, y) { x + y; }; add(5 + 5, add(5, 5));", 20},
+		{"let five = 5; let ten = 10; let add = fn(x, y) { x + y; }; add(five, ten);", 15},
+		{"let f = fn(x) { if (x > 0) { return 1; } return 2; }; f(1) + f(0);", 3},
+		{"let fact = fn(n) { if (n < 2) { return 1; } n * fact(n - 1); }; fact(10);", 3628800},
+		{"let f = fn() { let y = if (true) { return 7; }; 99 }; f()", 7},
+		{"fn() { [if (true) { return 1; }] }()", 1},
+		{"fn() { {\"a\": if (true) { return 2; }} }()", 2},
+		{"let id = fn(x) { x }; fn() { id(if (true) { return 3; }) }()", 3},
+		{"fn() { 1 + if (true) { return 4; } }()", 4},
+		{"fn() { return if (true) { return 5; } else { 6 }; }()", 5},
+	}
+
+	for _, testcase := range testcases {
+		testIntegerObject(t, testEval(testcase.expr), testcase.expected)
+	}
+}
+
+func TestCallingExpressions(t *testing.T) {
+	testcases := []struct {
+		expr     string
+		expected int64
+	}{
+		{"fn(x) { x; }(5)", 5},
+		{"let makeAdder = fn(x) { fn(y) { x + y } }; makeAdder(1)(2);", 3},
+		{"let f = fn(x) { x * 2 }; (f)(4);", 8},
+		{"if (true) { fn() { 7 } } else { fn() { 8 } }()", 7},
+	}
+
+	for _, testcase := range testcases {
+		testIntegerObject(t, testEval(testcase.expr), testcase.expected)
+	}
+}
+
+func TestArrayLiterals(t *testing.T) {
+	obj := testEval("[1, 2 * 2, 3 + 3]")
+	array, ok := obj.(*object.Array)
+	if !ok {
+		t.Fatalf("object is not Array. got=%T (%v)", obj, obj)
+	}
+	if len(array.Elements) != 3 {
+		t.Fatalf("array has wrong number of elements. got=%d", len(array.Elements))
+	}
+	testIntegerObject(t, array.Elements[0], 1)
+	testIntegerObject(t, array.Elements[1], 4)
+	testIntegerObject(t, array.Elements[2], 6)
+}
+
+func TestArrayIndexExpressions(t *testing.T) {
+	testcases := []struct {
+		expr     string
+		expected int64
+	}{
+		{"[1, 2, 3][0]", 1},
+		{"[1, 2, 3][2]", 3},
+		{"let i = 0; [1][i];", 1},
+		{"[1, 2, 3][1 + 1];", 3},
+		{"let a = [1, 2, 3]; a[0] + a[1] + a[2];", 6},
+		{"[1, 2, 3][-1]", 3},
+		{"[1, 2, 3][-3]", 1},
+		{"let fns = [fn(x) { x * 2 }]; fns[0](21)", 42},
+	}
+
+	for _, testcase := range testcases {
+		testIntegerObject(t, testEval(testcase.expr), testcase.expected)
+	}
+}
+
+func TestArrayBuiltins(t *testing.T) {
+	testcases := []struct {
+		expr     string
+		expected any
+	}{
+		{"len([])", 0},
+		{"len([1, 2, 3])", 3},
+		{`len("hello")`, 5},
+		{"first([1, 2, 3])", 1},
+		{"first([])", nil},
+		{"last([1, 2, 3])", 3},
+		{"last([])", nil},
+		{"len(rest([1, 2, 3]))", 2},
+		{"first(rest([1, 2, 3]))", 2},
+		{"rest([])", nil},
+		{"let a = [1]; let b = push(a, 2); len(a) * 10 + len(b)", 12},
+		{"last(push([1], 5))", 5},
+	}
+
+	for _, testcase := range testcases {
+		obj := testEval(testcase.expr)
+		integer, ok := testcase.expected.(int)
+		if ok {
+			testIntegerObject(t, obj, int64(integer))
+		} else if obj != NULL {
+			t.Errorf("object is not NULL. got=%T (%v)", obj, obj)
+		}
+	}
+}
+
+func TestArrayErrors(t *testing.T) {
+	testcases := []struct {
+		expr    string
+		kind    object.ErrorKind
+		message string
+	}{
+		{"[1, 2, 3][3]", object.INDEX_ERROR, "array index out of range: 3 (length 3)"},
+		{"[1, 2, 3][-4]", object.INDEX_ERROR, "array index out of range: -4 (length 3)"},
+		{"[1][true]", object.TYPE_ERROR, "array index must be INTEGER, got=Boolean"},
+		{"1[0]", object.TYPE_ERROR, "index operator not supported: INTEGER"},
+		{"len(1)", object.TYPE_ERROR, "argument to len not supported: got=INTEGER"},
+		{"len([1], [2])", object.ARGUMENT_ERROR, "wrong number of arguments to len: expected=1, got=2"},
+		{"push(1, 1)", object.TYPE_ERROR, "argument to push not supported: got=INTEGER"},
+	}
+
+	for _, testcase := range testcases {
+		testErrorObject(t, testEval(testcase.expr), testcase.kind, testcase.message)
+	}
+}
+
+func TestHashLiterals(t *testing.T) {
+	input := `let two = "two";
+{
+  "one": 10 - 9,
+  two: 1 + 1,
+  "thr" + "ee": 6 / 2,
+  4: 4,
+  true: 5,
+  false: 6
+}`
+
+	obj := testEval(input)
+	hash, ok := obj.(*object.Hash)
+	if !ok {
+		t.Fatalf("object is not Hash. got=%T (%v)", obj, obj)
+	}
+
+	expected := []struct {
+		key   object.Hashable
+		value int64
+	}{
+		{&object.String{Value: "one"}, 1},
+		{&object.String{Value: "two"}, 2},
+		{&object.String{Value: "three"}, 3},
+		{&object.Integer{Value: 4}, 4},
+		{TRUE, 5},
+		{FALSE, 6},
+	}
+	if hash.Len() != len(expected) {
+		t.Fatalf("hash has wrong number of pairs. got=%d", hash.Len())
+	}
+	for i, pair := range hash.Pairs() {
+		if pair.Key.Inspect() != expected[i].key.Inspect() {
+			t.Errorf("pair[%d] - wrong key. expected=%s, got=%s",
+				i, expected[i].key.Inspect(), pair.Key.Inspect())
+		}
+	}
+	for _, tt := range expected {
+		value, ok := hash.Get(tt.key)
+		if !ok {
+			t.Errorf("no pair for key %s", tt.key.Inspect())
+			continue
+		}
+		testIntegerObject(t, value, tt.value)
+	}
+}
+
+func TestHashIndexExpressions(t *testing.T) {
+	testcases := []struct {
+		expr     string
+		expected any
+	}{
+		{`{"foo": 5}["foo"]`, 5},
+		{`{"foo": 5}["bar"]`, nil},
+		{`let key = "foo"; {"foo": 5}[key]`, 5},
+		{`{}["foo"]`, nil},
+		{`{5: 5}[5]`, 5},
+		{`{true: 5}[true]`, 5},
+		{`{1: 1, 1: 2}[1]`, 2},
+		{`len({"a": 1, "b": 2})`, 2},
+	}
+
+	for _, testcase := range testcases {
+		obj := testEval(testcase.expr)
+		integer, ok := testcase.expected.(int)
+		if ok {
+			testIntegerObject(t, obj, int64(integer))
+		} else if obj != NULL {
+			t.Errorf("object is not NULL. got=%T (%v)", obj, obj)
+		}
+	}
+}
+
+func TestUnhashableKeys(t *testing.T) {
+	testcases := []struct {
+		expr    string
+		message string
+		line    int
+		column  int
+	}{
+		{`{"name": 1}[fn(x) { x }]`, "unusable as hash key: FUNCTION", 1, 11},
+		{"let h = {};\nh[[1]]", "unusable as hash key: ARRAY", 2, 1},
+		{"let h = {\n  [1]: 2 };", "unusable as hash key: ARRAY", 2, 2},
+		{`{"a": 1, [1]: 2}`, "unusable as hash key: ARRAY", 1, 9},
+		{`let f = fn() { [] }; {f(): 2}`, "unusable as hash key: ARRAY", 1, 22},
+	}
+
+	for _, testcase := range testcases {
+		obj := testEval(testcase.expr)
+		if !testErrorObject(t, obj, object.TYPE_ERROR, testcase.message) {
+			continue
+		}
+		errobj := obj.(*object.Error)
+		if errobj.Token.Line != testcase.line || errobj.Token.Column != testcase.column {
+			t.Errorf("wrong error position for %q. expected=%d:%d, got=%d:%d", testcase.expr,
+				testcase.line, testcase.column, errobj.Token.Line, errobj.Token.Column)
+		}
+	}
+}
+
+func TestStringOperations(t *testing.T) {
+	testcases := []struct {
+		expr     string
+		expected any
+	}{
+		{`"héllo"[1]`, "é"},
+		{`"héllo"[-1]`, "o"},
+		{`"日本語"[2]`, "語"},
+		{`"héllo"[1:3]`, "él"},
+		{`"héllo"[:2]`, "hé"},
+		{`"héllo"[3:]`, "lo"},
+		{`"héllo"[-2:]`, "lo"},
+		{`"héllo"[4:1]`, ""},
+		{`"abc"[:100]`, "abc"},
+		{`[1, 2, 3, 4][1:3]`, "[2, 3]"},
+		{`len("日本語")`, 3},
+		{`"a" < "b"`, true},
+		{`"abc" >= "abd"`, false},
+		{`split("a,b,c", ",")`, "[a, b, c]"},
+		{`join(["a", "b", "c"], "-")`, "a-b-c"},
+		{`upper("héllo")`, "HÉLLO"},
+		{`lower("HeLLo")`, "hello"},
+		{`trim("  hi\t")`, "hi"},
+		{`contains("monkey", "key")`, true},
+		{`contains("monkey", "donkey")`, false},
+		{`replace("a-b-c", "-", "+")`, "a+b+c"},
+		{`format("{} + {} = {}", 1, 2, "3")`, "1 + 2 = 3"},
+		{`format("no placeholders")`, "no placeholders"},
+	}
+
+	for _, testcase := range testcases {
+		obj := testEval(testcase.expr)
+		switch expected := testcase.expected.(type) {
+		case int:
+			testIntegerObject(t, obj, int64(expected))
+		case bool:
+			testBooleanObject(t, obj, expected)
+		case string:
+			if obj == nil || obj.Inspect() != expected {
+				t.Errorf("wrong result for %s. expected=%q, got=%v", testcase.expr, expected, obj)
+			}
+		}
+	}
+}
+
+func TestInterpolatedStrings(t *testing.T) {
+	testcases := []struct {
+		expr     string
+		expected string
+	}{
+		{`let name = "Ada"; "Hello ${name}"`, "Hello Ada"},
+		{`let items = [1, 2]; "you have ${len(items)} items"`, "you have 2 items"},
+		{`"${1.5 * 2} ${true} ${[1, "a"]} ${if (false) { 1 }}"`, "3.0 true [1, a] null"},
+		{`let f = fn(x) { "<${x}>" }; "${f("${1 + 1}")}"`, "<2>"},
+		{`"cost: \${price}"`, "cost: ${price}"},
+	}
+
+	for _, testcase := range testcases {
+		obj := testEval(testcase.expr)
+		str, ok := obj.(*object.String)
+		if !ok {
+			t.Errorf("object is not String. got=%T (%v)", obj, obj)
+			continue
+		}
+		if str.Value != testcase.expected {
+			t.Errorf("wrong value. expected=%q, got=%q", testcase.expected, str.Value)
+		}
+	}
+
+	testErrorObject(t, testEval(`"a ${missing} b"`), object.NAME_ERROR, "identifier not found: missing")
+}
+
+func TestStringErrors(t *testing.T) {
+	testcases := []struct {
+		expr    string
+		kind    object.ErrorKind
+		message string
+	}{
+		{`"abc"[3]`, object.INDEX_ERROR, "string index out of range: 3 (length 3)"},
+		{`"abc"["a"]`, object.TYPE_ERROR, "string index must be INTEGER, got=STRING"},
+		{`"abc"[true:]`, object.TYPE_ERROR, "slice bound must be INTEGER, got=Boolean"},
+		{`1[1:]`, object.TYPE_ERROR, "slice operator not supported: INTEGER"},
+		{`join([1, 2], ",")`, object.TYPE_ERROR, "argument to join not supported: got=INTEGER"},
+		{`upper(1)`, object.TYPE_ERROR, "argument to upper not supported: got=INTEGER"},
+		{`format("{}")`, object.ARGUMENT_ERROR,
+			"wrong number of arguments to format: format string has 1 placeholders, got=0"},
+		{`format()`, object.ARGUMENT_ERROR, "wrong number of arguments to format: expected at least 1, got=0"},
+		{`int("4x")`, object.VALUE_ERROR, `invalid integer: "4x"`},
+		{`float("pi")`, object.VALUE_ERROR, `invalid float: "pi"`},
+		{`int(1e300)`, object.VALUE_ERROR, "cannot convert 1e+300 to INTEGER"},
+		{`1.0 / 0`, object.ZERO_DIVISION, "division by zero"},
+		{`int([])`, object.TYPE_ERROR, "argument to int not supported: got=ARRAY"},
+	}
+
+	for _, testcase := range testcases {
+		testErrorObject(t, testEval(testcase.expr), testcase.kind, testcase.message)
+	}
+}
+
+func TestClosures(t *testing.T) {
+	input := `
+let newAdder = fn(x) {
+  fn(y) { x + y };
+};
+let addTwo = newAdder(2);
+addTwo(3);`
+
+	testIntegerObject(t, testEval(input), 5)
+}
+
+func TestAssignments(t *testing.T) {
+	testcases := []struct {
+		expr     string
+		expected int64
+	}{
+		{"let x = 1; x = 5; x", 5},
+		{"let x = 1; x = 5", 5},
+		{"let x = 10; x += 2; x -= 4; x *= 3; x /= 4; x", 6},
+		{"let x = 1; let y = 2; x = y = 7; x + y", 14},
+		{"let x = 1; (x) = 4; x", 4},
+		{"let a = [1, 2, 3]; a[0] = 9; a[-1] *= 10; a[0] + a[1] + a[2]", 41},
+		{`let h = {"k": 1}; h["k"] += 1; h["new"] = 5; h["k"] + h["new"]`, 7},
+		// the nearest enclosing binding is updated
+		{"let n = 0; let inc = fn() { n += 1 }; inc(); inc(); n", 2},
+		{"let n = 0; let f = fn() { let n = 5; n = 6 }; f(); n", 0},
+		{"let n = 0; if (true) { n = 3 }; n", 3},
+		// arrays and hashes are updated in place
+		{"let a = [1]; let b = a; b[0] = 2; a[0]", 2},
+		{"let count = fn() { let c = 0; fn() { c += 1 } }; let next = count(); next(); next()", 2},
+	}
+
+	for _, testcase := range testcases {
+		testIntegerObject(t, testEval(testcase.expr), testcase.expected)
+	}
+
+	obj := testEval(`let s = "a"; s += "b"; s`)
+	if str, ok := obj.(*object.String); !ok || str.Value != "ab" {
+		t.Errorf("object is not String ab. got=%T (%v)", obj, obj)
+	}
+}
+
+func TestAssignmentErrors(t *testing.T) {
+	testcases := []struct {
+		expr    string
+		kind    object.ErrorKind
+		message string
+	}{
+		{"x = 1", object.NAME_ERROR, "cannot assign to undeclared identifier: x"},
+		{"let f = fn() { y = 1 }; f()", object.NAME_ERROR, "cannot assign to undeclared identifier: y"},
+		{"x += 1", object.NAME_ERROR, "identifier not found: x"},
+		{"len = 1", object.NAME_ERROR, "cannot assign to undeclared identifier: len"},
+		{"let x = 1; x /= 0", object.ZERO_DIVISION, "division by zero"},
+		{`let x = 1; x += "a"`, object.TYPE_ERROR, "type mismatch: INTEGER + STRING"},
+		{"let a = [1]; a[1] = 2", object.INDEX_ERROR, "array index out of range: 1 (length 1)"},
+		{`let a = [1]; a["0"] = 2`, object.TYPE_ERROR, "array index must be INTEGER, got=STRING"},
+		{"let h = {}; h[[]] = 2", object.TYPE_ERROR, "unusable as hash key: ARRAY"},
+		{`let s = "ab"; s[0] = "c"`, object.TYPE_ERROR, "index assignment not supported: STRING"},
+	}
+
+	for _, testcase := range testcases {
+		testErrorObject(t, testEval(testcase.expr), testcase.kind, testcase.message)
+	}
+}
+
+func TestLoops(t *testing.T) {
+	testcases := []struct {
+		expr     string
+		expected int64
+	}{
+		{"let i = 0; while (i < 10) { i += 1; } i", 10},
+		{"let s = 0; for (let i = 1; i <= 100; i += 1) { s += i; } s", 5050},
+		{"let s = 0; let i = 0; for (; i < 3;) { i += 1; s += i; } s * 10 + i", 63},
+		{"let n = 0; for (;;) { n += 1; if (n == 7) { break; } } n", 7},
+		{"let s = 0; for (x in [1, 2, 3]) { s += x; } s", 6},
+		{"let s = 0; for (x in range(5)) { s += x; } s", 10},
+		{"let s = 0; for (x in range(10, 0, -3)) { s += x; } s", 22},
+		{`let s = 0; for (k in {"a": 1, "b": 2}) { s += len(k); } s`, 2},
+		{"let n = 0; for (c in \"héllo\") { n += 1; } n", 5},
+		// continue skips to the update of a for loop
+		{"let s = 0; for (let i = 0; i < 10; i += 1) { if (i % 2 == 0) { continue; } s += i; } s", 25},
+		// labels refer to outer loops
+		{`let n = 0;
+		  outer: for (i in range(10)) {
+		    for (j in range(10)) {
+		      if (j > i) { continue outer; }
+		      if (i == 5) { break outer; }
+		      n += 1;
+		    }
+		  }
+		  n`, 15},
+		{"let n = 0; a: while (true) { while (true) { break a; } n = 99; } n", 0},
+		// return stops the loop and the function
+		{"let f = fn() { for (x in [3, 4, 5]) { if (x > 3) { return x; } } 0 }; f()", 4},
+		// break and continue take effect inside expressions too
+		{"let n = 0; while (n < 5) { n += 1; let x = if (n == 2) { break; }; }; n", 2},
+		{"let m = 0; for (i in [1, 2, 3]) { m = if (i == 2) { continue; } else { m + i }; } m", 4},
+		{"let n = 0; while (true) { n += 1; n + if (n == 3) { break; } else { 0 }; } n", 3},
+		{"let a = []; for (i in [1, 2, 3]) { a = push(a, [if (i == 2) { continue; } else { i }]); } len(a)", 2},
+		// the variables of a for loop are local to it
+		{"let i = 42; for (let i = 0; i < 3; i += 1) {} i", 42},
+		{"let x = 42; for (x in [1, 2]) {} x", 42},
+		// each iteration has its own binding for closures to capture
+		{"let fs = []; for (x in [1, 2]) { fs = push(fs, fn() { x }); } fs[0]() * 10 + fs[1]()", 12},
+		// loops do not grow the stack
+		{"let i = 0; while (i < 100000) { i += 1; } i", 100000},
+		{"len(range(2, 9, 3))", 3},
+		{"len(range(5, 0))", 0},
+		{"len(range(0, 9223372036854775807))", 9223372036854775807},
+		{"len(range(9223372036854775807, -9223372036854775807 - 1, -3))", 6148914691236517205},
+	}
+
+	for _, testcase := range testcases {
+		testIntegerObject(t, testEval(testcase.expr), testcase.expected)
+	}
+
+	if obj := testEval("while (false) {}"); obj != NULL {
+		t.Errorf("loop did not evaluate to NULL. got=%T (%v)", obj, obj)
+	}
+	if obj := testEval("range(3)"); obj.Inspect() != "range(0, 3, 1)" {
+		t.Errorf("range inspected wrong. got=%q", obj.Inspect())
+	}
+}
+
+func TestLoopErrors(t *testing.T) {
+	testcases := []struct {
+		expr    string
+		kind    object.ErrorKind
+		message string
+	}{
+		{"for (x in 5) {}", object.TYPE_ERROR, "cannot iterate over INTEGER"},
+		{"while (x) {}", object.NAME_ERROR, "identifier not found: x"},
+		{`for (let i = 0; i < 3; i += "a") {}`, object.TYPE_ERROR, "type mismatch: INTEGER + STRING"},
+		{"for (x in [1, 2]) { x / 0; }", object.ZERO_DIVISION, "division by zero"},
+		{"range(1, 2, 0)", object.VALUE_ERROR, "range step cannot be zero"},
+		{"range()", object.ARGUMENT_ERROR, "wrong number of arguments to range: expected 1 to 3, got=0"},
+		{`range("3")`, object.TYPE_ERROR, "argument to range not supported: got=STRING"},
+		{"range(-9223372036854775807 - 1, 9223372036854775807)", object.VALUE_ERROR,
+			"range too long: more than 9223372036854775807 integers"},
+		{"range(9223372036854775807, -9223372036854775807 - 1, -2)", object.VALUE_ERROR,
+			"range too long: more than 9223372036854775807 integers"},
+	}
+
+	for _, testcase := range testcases {
+		testErrorObject(t, testEval(testcase.expr), testcase.kind, testcase.message)
+	}
+}
+
+func TestFunctionCallErrors(t *testing.T) {
+	testcases := []struct {
+		expr     string
+		expected string
+		column   int
+	}{
+		{"let f = fn(x) { x }; f(1, 2);", "wrong number of arguments to f: expected=1, got=2", 21},
+		{"let f = 5; f();", "not a function: INTEGER", 11},
+		{"let f = fn(x) { x }; f(y);", "identifier not found: y", 23},
+		// errors point at the start of the callee
+		{"let a = [5]; a[0]();", "not a function: INTEGER", 13},
+		{"fn(x, y) { x }(1);", "wrong number of arguments to <anonymous>: expected=2, got=1", 0},
+		{"len(1, 2);", "wrong number of arguments to len: expected=1, got=2", 0},
+	}
+
+	for _, testcase := range testcases {
+		obj := testEval(testcase.expr)
+		errobj, ok := obj.(*object.Error)
+		if !ok {
+			t.Errorf("object is not Error. got=%T (%v)", obj, obj)
+			continue
+		}
+		if errobj.Message != testcase.expected {
+			t.Errorf("wrong error message. expected=%q, got=%q", testcase.expected, errobj.Message)
+		}
+		if errobj.Token.Column != testcase.column {
+			t.Errorf("wrong error column for %q. expected=%d, got=%d",
+				testcase.expr, testcase.column, errobj.Token.Column)
+		}
+	}
+}
+
+func TestErrorHandling(t *testing.T) {
+	testcases := []struct {
+		expr    string
+		kind    object.ErrorKind
+		message string
+		line    int
+		column  int
+	}{
+		{"5 + true;", object.TYPE_ERROR, "type mismatch: INTEGER + Boolean", 1, 2},
+		{"5 + true; 5;", object.TYPE_ERROR, "type mismatch: INTEGER + Boolean", 1, 2},
+		{"-true", object.TYPE_ERROR, "unknown operator: -Boolean", 1, 0},
+		{"true + false;", object.TYPE_ERROR, "unknown operator: Boolean + Boolean", 1, 5},
+		{"\"a\" - \"b\"", object.TYPE_ERROR, "unknown operator: STRING - STRING", 1, 4},
+		{"if (10 > 1) { true + false; }", object.TYPE_ERROR, "unknown operator: Boolean + Boolean", 1, 19},
+		{"let f = fn() { return 1 / 0; }; f(); 10;", object.ZERO_DIVISION, "division by zero", 1, 24},
+		{"foobar", object.NAME_ERROR, "identifier not found: foobar", 1, 0},
+		{"5 % 0", object.ZERO_DIVISION, "modulo by zero", 1, 2},
+		{"1.5 % 0", object.ZERO_DIVISION, "modulo by zero", 1, 4},
+		{"1 << -1", object.VALUE_ERROR, "negative shift count: -1", 1, 2},
+		{"1.5 & 1", object.TYPE_ERROR, "unknown operator: FLOAT & FLOAT", 1, 4},
+		{"~1.5", object.TYPE_ERROR, "unknown operator: ~FLOAT", 1, 0},
+		{"true && 1 + true", object.TYPE_ERROR, "type mismatch: INTEGER + Boolean", 1, 10},
+	}
+
+	for _, testcase := range testcases {
+		obj := testEval(testcase.expr)
+		if !testErrorObject(t, obj, testcase.kind, testcase.message) {
+			continue
+		}
+		errobj := obj.(*object.Error)
+		if errobj.Token.Line != testcase.line || errobj.Token.Column != testcase.column {
+			t.Errorf("wrong error position for %q. expected=%d:%d, got=%d:%d", testcase.expr,
+				testcase.line, testcase.column, errobj.Token.Line, errobj.Token.Column)
+		}
+	}
+}
+
+func TestErrorInspect(t *testing.T) {
+	p := parser.New(lexer.NewReader(strings.NewReader("let x = 1;\nx + true"), "a.monkey"))
+	obj := Eval(p.ParseProgram(), object.NewEnvironment())
+	expected := "a.monkey:2:3: TypeError: type mismatch: INTEGER + Boolean"
+	if obj == nil || obj.Inspect() != expected {
+		t.Errorf("error inspected wrong. expected=%q, got=%v", expected, obj)
+	}
+}
+
+func TestStackTrace(t *testing.T) {
+	input := `let inner = fn(x) { x + true };
+let outer = fn(x) {
+  inner(x)
+};
+let apply = fn(f) { f() };
+apply(fn() { outer(1) });`
+
+	obj := testEval(input)
+	errobj, ok := obj.(*object.Error)
+	if !ok {
+		t.Fatalf("object is not Error. got=%T (%v)", obj, obj)
+	}
+
+	expected := []struct {
+		function string
+		line     int
+		column   int
+	}{
+		{"inner", 3, 2},
+		{"outer", 6, 13},
+		{"<anonymous>", 5, 20},
+		{"apply", 6, 0},
+	}
+	if len(errobj.Stack) != len(expected) {
+		t.Fatalf("wrong stack depth. expected=%d, got=%d", len(expected), len(errobj.Stack))
+	}
+	for i, frame := range errobj.Stack {
+		if frame.Function != expected[i].function {
+			t.Errorf("frame[%d] - wrong function name. expected=%q, got=%q",
+				i, expected[i].function, frame.Function)
+		}
+		if frame.CallSite.Line != expected[i].line || frame.CallSite.Column != expected[i].column {
+			t.Errorf("frame[%d] - wrong call site. expected=%d:%d, got=%d:%d", i,
+				expected[i].line, expected[i].column, frame.CallSite.Line, frame.CallSite.Column)
+		}
+	}
+}
+
 func testEval(src string) object.Object {
 	p := parser.New(lexer.New(src))
 	prog := p.ParseProgram()
-	return Eval(prog)
+	return Eval(prog, object.NewEnvironment())
+}
+
+func testErrorObject(t *testing.T, obj object.Object, kind object.ErrorKind, message string) bool {
+	errobj, ok := obj.(*object.Error)
+	if !ok {
+		t.Errorf("object is not Error. got=%T (%v)", obj, obj)
+		return false
+	}
+	if errobj.Kind != kind || errobj.Message != message {
+		t.Errorf("wrong error. expected=%s %q, got=%s %q", kind, message, errobj.Kind, errobj.Message)
+		return false
+	}
+	return true
 }
 
 func testIntegerObject(t *testing.T, obj object.Object, expected int64) bool {
@@ -41,3 +904,16 @@ func testIntegerObject(t *testing.T, obj object.Object, expected int64) bool {
 	}
 	return true
 }
+
+func testBooleanObject(t *testing.T, obj object.Object, expected bool) bool {
+	bobj, ok := obj.(*object.Boolean)
+	if !ok {
+		t.Errorf("object is not Boolean. got=%T (%v)", obj, obj)
+		return false
+	}
+	if bobj.Value != expected {
+		t.Errorf("object value does not match. got=%t. exprected=%t", bobj.Value, expected)
+		return false
+	}
+	return true
+}
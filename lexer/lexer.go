@@ -1,161 +1,661 @@
 package lexer
 
 import (
+	"bufio"
 	"fmt"
 	"io"
 	"os"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/px86/monkey/token"
 )
 
+// The lexer decodes the source as UTF-8. Columns count runes, while
+// offsets count bytes. The source is read incrementally through a buffer
+// of fixed size, so that memory use does not grow with the source.
 type Lexer struct {
-	source string
-	pos    int
+	reader *bufio.Reader
+	closer io.Closer   // closed by Close, or once the end of the source is reached
+	file   *token.File // records the lines of the source as they are read
+	pos    int         // byte offset of the current rune
 	line   int
 	column int
 	eof    bool
+
+	Errors []error
+}
+
+// Size of the read buffer. It needs to hold the longest lookahead of the
+// lexer, which is a few bytes.
+const bufferSize = 4096
+
+// Error is a diagnostic for invalid input found by the lexer. Pos and End
+// delimit the offending source, while Line and Column locate its start.
+// Help suggests a fix, if there is an obvious one.
+type Error struct {
+	Pos    token.Pos
+	End    token.Pos
+	Line   int
+	Column int
+	Msg    string
+	Help   string
+}
+
+func (e *Error) Error() string {
+	// columns are shown starting at 1, as in token.Position
+	return fmt.Sprintf("at line:%d, column:%d, %s", e.Line, e.Column+1, e.Msg)
+}
+
+// Report an error about the source from start up to the current position.
+func (lex *Lexer) errorf(start token.Pos, format string, a ...any) {
+	pos := lex.file.Position(start)
+	lex.Errors = append(lex.Errors, &Error{
+		Pos:    start,
+		End:    lex.here(),
+		Line:   pos.Line,
+		Column: pos.Column,
+		Msg:    fmt.Sprintf(format, a...),
+	})
+}
+
+// Attach a suggestion to the last reported error.
+func (lex *Lexer) help(text string) {
+	lex.Errors[len(lex.Errors)-1].(*Error).Help = text
 }
 
 func New(source string) *Lexer {
+	return NewReader(strings.NewReader(source), "")
+}
+
+// NewReader returns a lexer reading the source from r. The filename is
+// recorded in the position of every token.
+func NewReader(r io.Reader, filename string) *Lexer {
+	return NewFileReader(token.NewFileSet().AddFile(filename), r)
+}
+
+// NewFileReader returns a lexer reading the source of file from r. The
+// file, which must be empty, is filled in as the source is read; it is
+// used to lex several files into one token.FileSet.
+func NewFileReader(file *token.File, r io.Reader) *Lexer {
 	return &Lexer{
-		source: source,
+		reader: bufio.NewReaderSize(r, bufferSize),
+		file:   file,
 		line:   1,
 		column: 0,
 	}
 }
 
+// FromFilePath returns a lexer reading the file at path. The file is
+// closed when the lexer reaches its end, or by Close if lexing stops
+// earlier.
 func FromFilePath(path string) (*Lexer, error) {
+	return FromFilePathInSet(token.NewFileSet(), path)
+}
+
+// FromFilePathInSet is like FromFilePath, but adds the file to fset.
+func FromFilePathInSet(fset *token.FileSet, path string) (*Lexer, error) {
 	f, err := os.Open(path)
 	if err != nil {
 		return nil, err
 	}
-	bytes, err := io.ReadAll(f)
-	if err != nil {
-		return nil, err
+	lex := NewFileReader(fset.AddFile(path), f)
+	lex.closer = f
+	return lex, nil
+}
+
+// Close closes the file opened by FromFilePath, if it is not closed yet.
+// No tokens can be read after Close.
+func (lex *Lexer) Close() error {
+	if lex.closer == nil {
+		return nil
 	}
-	return &Lexer{
-		source: string(bytes),
-		line:   1,
-	}, nil
+	err := lex.closer.Close()
+	lex.closer = nil
+	return err
 }
 
-func (lex *Lexer) peek() byte {
-	if lex.pos >= len(lex.source) {
-		lex.eof = true
-		return 0
+// File returns the file being lexed.
+func (lex *Lexer) File() *token.File {
+	return lex.file
+}
+
+// Return the current rune and its size in bytes without consuming it.
+// Invalid UTF-8 is returned as utf8.RuneError of size 1.
+func (lex *Lexer) peekRune() (rune, int) {
+	buf, err := lex.reader.Peek(utf8.UTFMax)
+	if len(buf) == 0 {
+		lex.reachedEOF(err)
+		return 0, 0
 	}
-	return lex.source[lex.pos]
+	return utf8.DecodeRune(buf)
+}
+
+// Return the current rune without consuming it. Invalid UTF-8 is
+// returned as utf8.RuneError.
+func (lex *Lexer) peek() rune {
+	r, _ := lex.peekRune()
+	return r
 }
 
 func (lex *Lexer) consume() {
-	if lex.pos >= len(lex.source) {
-		lex.eof = true
+	r, size := lex.peekRune()
+	if size == 0 {
 		return
 	}
-	if lex.source[lex.pos] == '\n' {
+	lex.reader.Discard(size)
+	if r == '\n' {
 		lex.line++
 		lex.column = 0
+		lex.file.AddLine(lex.pos + size)
 	} else {
 		lex.column++
+		lex.file.AddRune(lex.pos, size)
 	}
-	lex.pos++
+	lex.pos += size
 }
 
+// Return the next n bytes, or fewer near the end of the source.
 func (lex *Lexer) peekN(n int) string {
-	if lex.pos+n < len(lex.source) {
-		return lex.source[lex.pos : lex.pos+n]
+	buf, _ := lex.reader.Peek(n)
+	return string(buf)
+}
+
+// Mark the end of the source. Read errors other than io.EOF are reported
+// as lexer errors, since the source can not be read any further.
+func (lex *Lexer) reachedEOF(err error) {
+	if lex.eof {
+		return
+	}
+	lex.eof = true
+	if err != nil && err != io.EOF {
+		lex.errorf(lex.here(), "%s", err)
+	}
+	lex.Close()
+}
+
+// Return the position of the current rune.
+func (lex *Lexer) here() token.Pos {
+	return lex.file.Pos(lex.pos)
+}
+
+// Return a token positioned at the current rune. Its end is set by
+// NextToken, once the token has been read.
+func (lex *Lexer) startToken(toktype token.TokenType) token.Token {
+	return token.Token{
+		Type:   toktype,
+		File:   lex.file.Name(),
+		Line:   lex.line,
+		Column: lex.column,
+		Offset: lex.pos,
+		Pos:    lex.here(),
 	}
-	return lex.source[lex.pos:]
 }
 
 func (lex *Lexer) singleCharToken(toktype token.TokenType) token.Token {
-	tok := token.Token{Type: toktype, Value: nil, Line: lex.line, Column: lex.column}
+	tok := lex.startToken(toktype)
 	lex.consume()
 	return tok
 }
 
 func (lex *Lexer) doubleCharToken(toktype token.TokenType) token.Token {
-	tok := token.Token{Type: toktype, Value: nil, Line: lex.line, Column: lex.column}
+	tok := lex.startToken(toktype)
 	lex.consume()
 	lex.consume()
 	return tok
 }
 
+// Lex an integer or a floating point number. A number is a float if it
+// has a fractional part (3.14), an exponent (1e9, 2.5e-3), or both.
+// Integers can also be written in hex (0xFF), octal (0o17) or binary
+// (0b1010). Underscores may separate digits, as in 1_000_000.
 func (lex *Lexer) numberLiteralToken() token.Token {
-	// TODO: check floating point numbers
-	tok := token.Token{Line: lex.line, Column: lex.column}
+	tok := lex.startToken(token.INTEGER)
+
+	if prefix := lex.peekN(2); len(prefix) == 2 && prefix[0] == '0' {
+		switch prefix[1] {
+		case 'x', 'X':
+			return lex.radixLiteralToken(tok, 16, "hexadecimal")
+		case 'o', 'O':
+			return lex.radixLiteralToken(tok, 8, "octal")
+		case 'b', 'B':
+			return lex.radixLiteralToken(tok, 2, "binary")
+		}
+	}
 
-	var value int64
-	for d := lex.peek(); isDigit(d); d = lex.peek() {
-		value = 10*value + int64(int(d)-int('0'))
+	chars := lex.digits()
+	isFloat := false
+	// a fractional part needs at least one digit after the dot
+	if next := lex.peekN(2); len(next) == 2 && next[0] == '.' && isDigit(rune(next[1])) {
+		isFloat = true
 		lex.consume()
+		chars += "." + lex.digits()
+	}
+	if n := lex.exponentLength(); n > 0 {
+		isFloat = true
+		chars += lex.peekN(n)
+		for range n {
+			lex.consume()
+		}
+		chars += lex.digits()
+	}
+
+	if !validUnderscores(chars, isDigit) {
+		return lex.illegalNumber(tok, chars, "'_' must separate successive digits in %s", chars)
+	}
+	text := strings.ReplaceAll(chars, "_", "")
+
+	if isFloat {
+		value, err := strconv.ParseFloat(text, 64)
+		if err != nil {
+			return lex.illegalNumber(tok, chars, "float literal %s is out of range", chars)
+		}
+		tok.Type = token.FLOAT
+		tok.Value = value
+		return tok
+	}
+
+	value, err := strconv.ParseInt(text, 10, 64)
+	if err != nil {
+		return lex.illegalNumber(tok, chars, "integer literal %s overflows int64", chars)
 	}
 	tok.Type = token.INTEGER
 	tok.Value = value
 	return tok
 }
 
+// Lex an integer with a 0x, 0o or 0b prefix. Every alphanumeric character
+// following the prefix is part of the literal, so that 0b102 is reported
+// as an invalid binary literal rather than lexed as 0b10 followed by 2.
+func (lex *Lexer) radixLiteralToken(tok token.Token, base int, name string) token.Token {
+	chars := lex.peekN(2)
+	lex.consume()
+	lex.consume()
+	digits := ""
+	for c := lex.peek(); isIdentifierChar(c); c = lex.peek() {
+		digits += string(c)
+		lex.consume()
+	}
+	chars += digits
+
+	text := strings.ReplaceAll(digits, "_", "")
+	if text == "" {
+		return lex.illegalNumber(tok, chars, "%s literal %s has no digits", name, chars)
+	}
+	for _, d := range text {
+		if digitValue(d) >= base {
+			return lex.illegalNumber(tok, chars, "invalid digit %q in %s literal %s", d, name, chars)
+		}
+	}
+	// an underscore may also follow the prefix, as in 0x_FF
+	if !validUnderscores("0"+digits, isIdentifierChar) {
+		return lex.illegalNumber(tok, chars, "'_' must separate successive digits in %s", chars)
+	}
+
+	value, err := strconv.ParseInt(text, base, 64)
+	if err != nil {
+		return lex.illegalNumber(tok, chars, "integer literal %s overflows int64", chars)
+	}
+	tok.Type = token.INTEGER
+	tok.Value = value
+	return tok
+}
+
+// Read a run of decimal digits and underscores.
+func (lex *Lexer) digits() string {
+	chars := []byte{}
+	for c := lex.peek(); isDigit(c) || c == '_'; c = lex.peek() {
+		chars = append(chars, byte(c))
+		lex.consume()
+	}
+	return string(chars)
+}
+
+func (lex *Lexer) illegalNumber(tok token.Token, chars string, format string, a ...any) token.Token {
+	lex.errorf(tok.Pos, format, a...)
+	tok.Type = token.ILLEGAL
+	tok.Value = chars
+	return tok
+}
+
+// Underscores are only allowed between two digits.
+func validUnderscores(chars string, isDigit func(rune) bool) bool {
+	runes := []rune(chars)
+	for i, r := range runes {
+		if r != '_' {
+			continue
+		}
+		if i == 0 || i == len(runes)-1 || !isDigit(runes[i-1]) || !isDigit(runes[i+1]) {
+			return false
+		}
+	}
+	return true
+}
+
+// Return the length of the exponent marker at the current position,
+// i.e. 1 for e5, 2 for e+5 or e-5, and 0 if there is no exponent.
+func (lex *Lexer) exponentLength() int {
+	next := lex.peekN(3)
+	if len(next) < 2 || (next[0] != 'e' && next[0] != 'E') {
+		return 0
+	}
+	if isDigit(rune(next[1])) {
+		return 1
+	}
+	if len(next) == 3 && (next[1] == '+' || next[1] == '-') && isDigit(rune(next[2])) {
+		return 2
+	}
+	return 0
+}
+
 func (lex *Lexer) stringLiteralToken() token.Token {
 
 	if lex.peek() != '"' {
 		panic("*Lexer.stringLiteralToken(): lex.peek() is not a double quote character")
 	}
 
-	tok := token.Token{Line: lex.line, Column: lex.column}
+	tok := lex.startToken(token.STRING_LITERAL)
 	lex.consume()
 
 	chars := []byte{}
-	for c := lex.peek(); c != 0; c = lex.peek() {
-
-		// reached EOF, unterminated string literal
-		if c == 0 {
-			panic(fmt.Sprintf("%d:%d unterminated string literal", tok.Line, tok.Column))
-		}
+	parts := []token.StringPart{}
+	for c := lex.peek(); !lex.eof; c = lex.peek() {
 		// end of string literal
 		if c == '"' {
 			lex.consume()
-			tok.Value = string(chars)
-			tok.Type = token.STRING_LITERAL
-			break
+			if len(parts) == 0 {
+				tok.Value = string(chars)
+				tok.Type = token.STRING_LITERAL
+				return tok
+			}
+			if len(chars) > 0 {
+				parts = append(parts, token.StringPart{Text: string(chars)})
+			}
+			tok.Value = parts
+			tok.Type = token.INTERPOLATED_STRING
+			return tok
+		}
+		// embedded expression
+		if lex.peekN(2) == "${" {
+			if len(chars) > 0 {
+				parts = append(parts, token.StringPart{Text: string(chars)})
+				chars = []byte{}
+			}
+			tokens, ok := lex.interpolationTokens()
+			if !ok {
+				break
+			}
+			parts = append(parts, token.StringPart{Tokens: tokens})
+			continue
 		}
 		// escaped characters
 		if c == '\\' {
-			lex.consume() // consume the \ character
-			echar := lex.peek()
+			chars = lex.escapeSequence(chars)
+		} else {
+			chars = utf8.AppendRune(chars, c)
 			lex.consume()
-			switch echar {
-			case 'a':
-				chars = append(chars, '\a')
-			case 'n':
-				chars = append(chars, '\n')
-			case 't':
-				chars = append(chars, '\t')
-			case 'r':
-				chars = append(chars, '\r')
-			case 'v':
-				chars = append(chars, '\v')
-			case 'f':
-				chars = append(chars, '\f')
-			case '\\':
-				chars = append(chars, '\\')
-			case '"':
-				chars = append(chars, '"')
-			default:
-				panic(fmt.Sprintf("unknown escaped character \\%c", echar))
+		}
+	}
+
+	// reached EOF, unterminated string literal
+	lex.errorf(tok.Pos, "unterminated string literal")
+	lex.help(`add the closing "`)
+	tok.Type = token.ILLEGAL
+	tok.Value = string(chars)
+	return tok
+}
+
+// Lex the tokens of the ${expression} at the current position, up to the
+// matching }. The returned tokens end with the closing }, followed by an
+// EOF token at the same position. Returns false if the source ends before
+// the closing }.
+func (lex *Lexer) interpolationTokens() ([]token.Token, bool) {
+	start := lex.here()
+	lex.consume() // consume the $
+	lex.consume() // consume the {
+
+	tokens := []token.Token{}
+	depth := 0
+	for {
+		tok := lex.NextToken()
+		switch tok.Type {
+		case token.EOF:
+			lex.errorf(start, "unterminated interpolation, expected }")
+			return nil, false
+		case token.LEFT_BRACE:
+			depth++
+		case token.RIGHT_BRACE:
+			if depth == 0 {
+				eof := tok
+				eof.Type = token.EOF
+				return append(tokens, tok, eof), true
 			}
-		} else {
-			chars = append(chars, c)
+			depth--
+		}
+		tokens = append(tokens, tok)
+	}
+}
+
+// Lex a `raw string`. Raw strings may span several lines, and backslashes
+// have no special meaning in them.
+func (lex *Lexer) rawStringLiteralToken() token.Token {
+	tok := lex.startToken(token.STRING_LITERAL)
+	lex.consume() // consume the opening `
+
+	chars := []byte{}
+	for c := lex.peek(); !lex.eof; c = lex.peek() {
+		lex.consume()
+		if c == '`' {
+			tok.Value = string(chars)
+			return tok
+		}
+		chars = utf8.AppendRune(chars, c)
+	}
+
+	lex.errorf(tok.Pos, "unterminated raw string literal")
+	lex.help("add the closing `")
+	tok.Type = token.ILLEGAL
+	tok.Value = string(chars)
+	return tok
+}
+
+// Lex a """triple quoted""" string. The common leading indentation of
+// the lines is removed, as well as the line break following the opening
+// quotes and the line holding the closing quotes if it is blank. Escape
+// sequences are processed after the indentation is removed.
+func (lex *Lexer) tripleQuotedStringToken() token.Token {
+	tok := lex.startToken(token.STRING_LITERAL)
+	for range 3 {
+		lex.consume()
+	}
+
+	chars := []byte{}
+	for c := lex.peek(); !lex.eof; c = lex.peek() {
+		if lex.peekN(3) == `"""` {
+			for range 3 {
+				lex.consume()
+			}
+			tok.Value = lex.unescape(tok, dedent(string(chars)))
+			return tok
+		}
+		lex.consume()
+		chars = utf8.AppendRune(chars, c)
+		// keep escape sequences for unescape, so that \" can not end the string
+		if c == '\\' && !lex.eof {
+			chars = utf8.AppendRune(chars, lex.peek())
 			lex.consume()
 		}
 	}
+
+	lex.errorf(tok.Pos, "unterminated triple quoted string literal")
+	lex.help(`add the closing """`)
+	tok.Type = token.ILLEGAL
+	tok.Value = string(chars)
 	return tok
 }
 
+// Remove the line break following the opening quotes, the last line if
+// it is blank, and the longest run of white space common to the start of
+// every non-blank line. The blank last line, which holds the closing
+// quotes, takes part in the computation of the common indentation.
+func dedent(s string) string {
+	s = strings.TrimPrefix(s, "\r\n")
+	s = strings.TrimPrefix(s, "\n")
+	lines := strings.Split(s, "\n")
+
+	candidates := []string{}
+	for _, line := range lines {
+		if strings.TrimSpace(line) != "" {
+			candidates = append(candidates, line)
+		}
+	}
+	if last := lines[len(lines)-1]; len(lines) > 1 && strings.TrimSpace(last) == "" {
+		lines = lines[:len(lines)-1]
+		candidates = append(candidates, last)
+	}
+
+	indent := ""
+	for i, line := range candidates {
+		lead := line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+		if i == 0 {
+			indent = lead
+		}
+		for !strings.HasPrefix(lead, indent) {
+			indent = indent[:len(indent)-1]
+		}
+	}
+
+	for i, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			lines[i] = ""
+		} else {
+			lines[i] = strings.TrimPrefix(line, indent)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Process the escape sequences in the contents of the string literal tok.
+// Since the contents may have been altered, errors are reported at the
+// start of the literal.
+func (lex *Lexer) unescape(tok token.Token, s string) string {
+	sub := New(s)
+	chars := []byte{}
+	for c := sub.peek(); !sub.eof; c = sub.peek() {
+		if c == '\\' {
+			chars = sub.escapeSequence(chars)
+		} else {
+			chars = utf8.AppendRune(chars, c)
+			sub.consume()
+		}
+	}
+	for _, err := range sub.Errors {
+		lex.errorf(tok.Pos, "%s", err.(*Error).Msg)
+	}
+	return string(chars)
+}
+
+// Decode the escape sequence at the current position, which is a
+// backslash, and append the resulting character to chars.
+func (lex *Lexer) escapeSequence(chars []byte) []byte {
+	start := lex.here()
+	lex.consume() // consume the \ character
+	echar := lex.peek()
+	if lex.eof {
+		return chars
+	}
+	lex.consume()
+	switch echar {
+	case 'a':
+		chars = append(chars, '\a')
+	case 'n':
+		chars = append(chars, '\n')
+	case 't':
+		chars = append(chars, '\t')
+	case 'r':
+		chars = append(chars, '\r')
+	case 'v':
+		chars = append(chars, '\v')
+	case 'f':
+		chars = append(chars, '\f')
+	case '\\':
+		chars = append(chars, '\\')
+	case '"':
+		chars = append(chars, '"')
+	case '$':
+		chars = append(chars, '$')
+	case 'x':
+		chars = utf8.AppendRune(chars, lex.hexEscape(start))
+	case 'u':
+		chars = utf8.AppendRune(chars, lex.unicodeEscape(start))
+	default:
+		// keep the character as is, so that lexing can go on
+		lex.errorf(start, "unknown escape sequence \\%c", echar)
+		chars = utf8.AppendRune(chars, echar)
+	}
+	return chars
+}
+
+// Lex the NN of a \xNN escape, which stands for the code point U+00NN.
+// start is the position of the backslash.
+func (lex *Lexer) hexEscape(start token.Pos) rune {
+	digits := ""
+	for range 2 {
+		c := lex.peek()
+		if digitValue(c) >= 16 {
+			break
+		}
+		digits += string(c)
+		lex.consume()
+	}
+	if len(digits) != 2 {
+		lex.errorf(start, "\\x must be followed by two hexadecimal digits")
+		return utf8.RuneError
+	}
+	value, _ := strconv.ParseUint(digits, 16, 8)
+	return rune(value)
+}
+
+// Lex the {N...} of a \u{N...} escape, with one to six hexadecimal
+// digits. start is the position of the backslash.
+func (lex *Lexer) unicodeEscape(start token.Pos) rune {
+	if lex.peek() != '{' {
+		lex.errorf(start, "\\u must be followed by {")
+		return utf8.RuneError
+	}
+	lex.consume()
+	digits := ""
+	for c := lex.peek(); digitValue(c) < 16; c = lex.peek() {
+		digits += string(c)
+		lex.consume()
+	}
+	if lex.peek() != '}' {
+		lex.errorf(start, "unterminated unicode escape, expected }")
+		return utf8.RuneError
+	}
+	lex.consume()
+	if len(digits) == 0 || len(digits) > 6 {
+		lex.errorf(start, "unicode escape must have between 1 and 6 hexadecimal digits")
+		return utf8.RuneError
+	}
+	value, _ := strconv.ParseUint(digits, 16, 32)
+	if !utf8.ValidRune(rune(value)) {
+		lex.errorf(start, "invalid unicode code point U+%X", value)
+		return utf8.RuneError
+	}
+	return rune(value)
+}
+
+// Identifiers follow the rules of Go: a letter or _ followed by letters,
+// digits and underscores, where letters and digits can be any Unicode
+// letter (category L) and decimal digit (category Nd).
 func (lex *Lexer) identifierOrKeywordToken() token.Token {
-	tok := token.Token{Line: lex.line, Column: lex.column}
+	tok := lex.startToken(token.IDENTIFIER)
 	chars := []byte{}
-	for c := lex.peek(); isAlphaNumeric(c) || c == '_'; c = lex.peek() {
-		chars = append(chars, c)
+	for c := lex.peek(); isIdentifierChar(c); c = lex.peek() {
+		chars = utf8.AppendRune(chars, c)
 		lex.consume()
 	}
 	value := string(chars)
@@ -168,30 +668,124 @@ func (lex *Lexer) identifierOrKeywordToken() token.Token {
 	return tok
 }
 
-func (lex *Lexer) NextToken() token.Token {
+// Skip white space, // line comments and /* */ block comments. Block
+// comments nest, so /* a /* b */ c */ is a single comment. Doc comments
+// (///) are not skipped, since they are returned as tokens.
+func (lex *Lexer) skipWhitespaceAndComments() {
+	for {
+		ch := lex.peek()
+		switch {
+		case isWhitespace(ch):
+			lex.consume()
+		case lex.peekN(2) == "//" && lex.peekN(3) != "///":
+			lex.skipLineComment()
+		case lex.peekN(4) == "////":
+			lex.skipLineComment()
+		case lex.peekN(2) == "/*":
+			lex.skipBlockComment()
+		default:
+			return
+		}
+	}
+}
 
-	ch := lex.peek()
-	for isWhitespace(ch) {
+func (lex *Lexer) skipLineComment() {
+	for c := lex.peek(); !lex.eof && c != '\n'; c = lex.peek() {
 		lex.consume()
-		ch = lex.peek()
 	}
+}
 
-	if ch == 0 {
-		return token.Token{Type: token.EOF, Value: nil, Line: lex.line, Column: lex.column}
+func (lex *Lexer) skipBlockComment() {
+	start := lex.here()
+	depth := 0
+	for !lex.eof {
+		switch lex.peekN(2) {
+		case "/*":
+			depth++
+			lex.consume()
+			lex.consume()
+		case "*/":
+			depth--
+			lex.consume()
+			lex.consume()
+			if depth == 0 {
+				return
+			}
+		default:
+			lex.consume()
+		}
+	}
+	lex.errorf(start, "unterminated block comment")
+	lex.help("add the closing */")
+}
+
+// Lex a /// comment up to the end of the line. The value is the text of
+// the comment without the slashes and the first space following them.
+func (lex *Lexer) docCommentToken() token.Token {
+	tok := lex.startToken(token.DOC_COMMENT)
+	for range 3 {
+		lex.consume()
+	}
+	if lex.peek() == ' ' {
+		lex.consume()
+	}
+	chars := []byte{}
+	for c := lex.peek(); !lex.eof && c != '\n'; c = lex.peek() {
+		chars = utf8.AppendRune(chars, c)
+		lex.consume()
+	}
+	tok.Value = strings.TrimRight(string(chars), "\r")
+	return tok
+}
+
+func (lex *Lexer) NextToken() token.Token {
+	tok := lex.nextToken()
+	tok.End = lex.here()
+	return tok
+}
+
+func (lex *Lexer) nextToken() token.Token {
+
+	lex.skipWhitespaceAndComments()
+	ch := lex.peek()
+
+	if lex.eof {
+		return lex.startToken(token.EOF)
 	}
 
 	switch {
 	case ch == '*':
+		if lex.peekN(2) == "**" {
+			return lex.doubleCharToken(token.ASTERISK_ASTERISK)
+		}
+		if lex.peekN(2) == "*=" {
+			return lex.doubleCharToken(token.ASTERISK_EQUAL)
+		}
 		return lex.singleCharToken(token.ASTERISK)
+	case ch == '%':
+		return lex.singleCharToken(token.PERCENT)
 	case ch == ',':
 		return lex.singleCharToken(token.COMMA)
+	case ch == ':':
+		return lex.singleCharToken(token.COLON)
 	case ch == '-':
+		if lex.peekN(2) == "-=" {
+			return lex.doubleCharToken(token.MINUS_EQUAL)
+		}
 		return lex.singleCharToken(token.MINUS)
 	case ch == '+':
+		if lex.peekN(2) == "+=" {
+			return lex.doubleCharToken(token.PLUS_EQUAL)
+		}
 		return lex.singleCharToken(token.PLUS)
 	case ch == ';':
 		return lex.singleCharToken(token.SEMI_COLON)
+	case lex.peekN(3) == "///":
+		return lex.docCommentToken()
 	case ch == '/':
+		if lex.peekN(2) == "/=" {
+			return lex.doubleCharToken(token.SLASH_EQUAL)
+		}
 		return lex.singleCharToken(token.SLASH)
 	case ch == '(':
 		return lex.singleCharToken(token.LEFT_PAREN)
@@ -224,12 +818,18 @@ func (lex *Lexer) NextToken() token.Token {
 		if lex.peekN(2) == ">=" {
 			return lex.doubleCharToken(token.GREATER_THAN_EQUAL)
 		}
+		if lex.peekN(2) == ">>" {
+			return lex.doubleCharToken(token.GREATER_GREATER)
+		}
 		return lex.singleCharToken(token.GREATER_THAN)
 
 	case ch == '<':
 		if lex.peekN(2) == "<=" {
 			return lex.doubleCharToken(token.LESSER_THAN_EQUAL)
 		}
+		if lex.peekN(2) == "<<" {
+			return lex.doubleCharToken(token.LESSER_LESSER)
+		}
 		return lex.singleCharToken(token.LESSER_THAN)
 	case ch == '&':
 		if lex.peekN(2) == "&&" {
@@ -242,39 +842,68 @@ func (lex *Lexer) NextToken() token.Token {
 		}
 		return lex.singleCharToken(token.PIPE)
 	// string literal
+	case lex.peekN(3) == `"""`:
+		return lex.tripleQuotedStringToken()
 	case ch == '"':
 		return lex.stringLiteralToken()
+	case ch == '`':
+		return lex.rawStringLiteralToken()
 	// number
 	case isDigit(ch):
 		return lex.numberLiteralToken()
 	// identifier or keyword
-	case isAlpha(ch):
+	case isLetter(ch):
 		return lex.identifierOrKeywordToken()
 	}
-	panic("control should not reach here!")
+
+	if _, size := lex.peekRune(); ch == utf8.RuneError && size == 1 {
+		raw := lex.peekN(1)
+		tok := lex.singleCharToken(token.ILLEGAL)
+		tok.Value = raw
+		lex.errorf(tok.Pos, "invalid UTF-8 encoding")
+		return tok
+	}
+	tok := lex.singleCharToken(token.ILLEGAL)
+	tok.Value = string(ch)
+	lex.errorf(tok.Pos, "unexpected character %q", ch)
+	lex.help("remove the character")
+	return tok
 }
 
-func isWhitespace(c byte) bool {
+func isWhitespace(c rune) bool {
 	if c == ' ' || c == '\t' || c == '\n' || c == '\v' || c == '\r' {
 		return true
 	}
 	return false
 }
 
-func isAlpha(c byte) bool {
-	if ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') {
-		return true
-	}
-	return false
+func isLetter(c rune) bool {
+	return c == '_' || unicode.IsLetter(c)
+}
+
+func isIdentifierChar(c rune) bool {
+	return isLetter(c) || unicode.IsDigit(c)
 }
 
-func isDigit(c byte) bool {
+// Numbers start with an ASCII digit, even though identifiers may contain
+// any Unicode decimal digit.
+func isDigit(c rune) bool {
 	if '0' <= c && c <= '9' {
 		return true
 	}
 	return false
 }
 
-func isAlphaNumeric(c byte) bool {
-	return (isAlpha(c) || isDigit(c))
+// Return the value of c as a digit in base 36, or 36 if c is not a digit.
+func digitValue(c rune) int {
+	switch {
+	case '0' <= c && c <= '9':
+		return int(c - '0')
+	case 'a' <= c && c <= 'z':
+		return int(c-'a') + 10
+	case 'A' <= c && c <= 'Z':
+		return int(c-'A') + 10
+	default:
+		return 36
+	}
 }
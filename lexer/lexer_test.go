@@ -2,7 +2,12 @@ package lexer
 
 import (
 	token "github.com/px86/monkey/token"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
+	"testing/iotest"
 )
 
 func TestNextToken(t *testing.T) {
@@ -110,6 +115,39 @@ func TestStringLiterals(t *testing.T) {
 	}
 }
 
+func TestNumberLiterals(t *testing.T) {
+	input := "42 3.14 1e9 2.5e-3 6E+2 2e 3e+"
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral any
+	}{
+		{token.INTEGER, int64(42)},
+		{token.FLOAT, 3.14},
+		{token.FLOAT, 1e9},
+		{token.FLOAT, 2.5e-3},
+		{token.FLOAT, 6e2},
+		{token.INTEGER, int64(2)},
+		{token.IDENTIFIER, "e"},
+		{token.INTEGER, int64(3)},
+		{token.IDENTIFIER, "e"},
+		{token.PLUS, nil},
+	}
+
+	l := New(input)
+	for i, tt := range tests {
+		tok := l.NextToken()
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q",
+				i, token.AsString(tt.expectedType), token.AsString(tok.Type))
+		}
+		if tok.Value != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%v, got=%v",
+				i, tt.expectedLiteral, tok.Value)
+		}
+	}
+}
+
 func TestLineAndColumn(t *testing.T) {
 	input := `let x = 1;
 let foo = "bar";`
@@ -163,7 +201,7 @@ let foo = "bar";`
 }
 
 func TestOperators(t *testing.T) {
-	input := "= == === ! != > >= < <= & && | || ^"
+	input := "= == === ! != > >= < <= & && | || ^ : ~ % * ** *** << >> <<< >>= += -= *= /= +== **="
 
 	tests := []struct {
 		expectedType token.TokenType
@@ -183,6 +221,27 @@ func TestOperators(t *testing.T) {
 		{token.PIPE},
 		{token.PIPE_PIPE},
 		{token.CARET},
+		{token.COLON},
+		{token.TILDE},
+		{token.PERCENT},
+		{token.ASTERISK},
+		{token.ASTERISK_ASTERISK},
+		{token.ASTERISK_ASTERISK},
+		{token.ASTERISK},
+		{token.LESSER_LESSER},
+		{token.GREATER_GREATER},
+		{token.LESSER_LESSER},
+		{token.LESSER_THAN},
+		{token.GREATER_GREATER},
+		{token.EQUAL},
+		{token.PLUS_EQUAL},
+		{token.MINUS_EQUAL},
+		{token.ASTERISK_EQUAL},
+		{token.SLASH_EQUAL},
+		{token.PLUS_EQUAL},
+		{token.EQUAL},
+		{token.ASTERISK_ASTERISK},
+		{token.EQUAL},
 	}
 
 	l := New(input)
@@ -194,3 +253,553 @@ func TestOperators(t *testing.T) {
 		}
 	}
 }
+
+func TestKeywords(t *testing.T) {
+	input := "while for in break continue whilst fork index"
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral any
+	}{
+		{token.KW_WHILE, "while"},
+		{token.KW_FOR, "for"},
+		{token.KW_IN, "in"},
+		{token.KW_BREAK, "break"},
+		{token.KW_CONTINUE, "continue"},
+		{token.IDENTIFIER, "whilst"},
+		{token.IDENTIFIER, "fork"},
+		{token.IDENTIFIER, "index"},
+		{token.EOF, nil},
+	}
+
+	l := New(input)
+	for i, tt := range tests {
+		tok := l.NextToken()
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q",
+				i, tt.expectedType, tok.Type)
+		}
+		if tok.Value != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q",
+				i, tt.expectedLiteral, tok.Value)
+		}
+	}
+}
+
+func TestIllegalInput(t *testing.T) {
+	input := `let x = 5 @ 3;
+"bad \q escape" # "open`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral any
+	}{
+		{token.KW_LET, "let"},
+		{token.IDENTIFIER, "x"},
+		{token.EQUAL, nil},
+		{token.INTEGER, int64(5)},
+		{token.ILLEGAL, "@"},
+		{token.INTEGER, int64(3)},
+		{token.SEMI_COLON, nil},
+		{token.STRING_LITERAL, "bad q escape"},
+		{token.ILLEGAL, "#"},
+		{token.ILLEGAL, "open"},
+		{token.EOF, nil},
+	}
+
+	l := New(input)
+	for i, tt := range tests {
+		tok := l.NextToken()
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q",
+				i, token.AsString(tt.expectedType), token.AsString(tok.Type))
+		}
+		if tok.Value != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%v, got=%v",
+				i, tt.expectedLiteral, tok.Value)
+		}
+	}
+
+	expectedErrors := []string{
+		"at line:1, column:11, unexpected character '@'",
+		"at line:2, column:6, unknown escape sequence \\q",
+		"at line:2, column:17, unexpected character '#'",
+		"at line:2, column:19, unterminated string literal",
+	}
+	if len(l.Errors) != len(expectedErrors) {
+		t.Fatalf("wrong number of errors. expected=%d, got=%d (%v)",
+			len(expectedErrors), len(l.Errors), l.Errors)
+	}
+	for i, err := range l.Errors {
+		if err.Error() != expectedErrors[i] {
+			t.Errorf("errors[%d] - wrong message. expected=%q, got=%q",
+				i, expectedErrors[i], err.Error())
+		}
+	}
+}
+
+func TestComments(t *testing.T) {
+	input := `// line comment
+let x = 1; // trailing comment
+/* block /* nested */ still comment */ let y = 2 / 1;
+//// not a doc comment
+/// Adds two numbers.
+///together
+let add = 3;
+/* unterminated`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral any
+	}{
+		{token.KW_LET, "let"},
+		{token.IDENTIFIER, "x"},
+		{token.EQUAL, nil},
+		{token.INTEGER, int64(1)},
+		{token.SEMI_COLON, nil},
+		{token.KW_LET, "let"},
+		{token.IDENTIFIER, "y"},
+		{token.EQUAL, nil},
+		{token.INTEGER, int64(2)},
+		{token.SLASH, nil},
+		{token.INTEGER, int64(1)},
+		{token.SEMI_COLON, nil},
+		{token.DOC_COMMENT, "Adds two numbers."},
+		{token.DOC_COMMENT, "together"},
+		{token.KW_LET, "let"},
+		{token.IDENTIFIER, "add"},
+		{token.EQUAL, nil},
+		{token.INTEGER, int64(3)},
+		{token.SEMI_COLON, nil},
+		{token.EOF, nil},
+	}
+
+	l := New(input)
+	for i, tt := range tests {
+		tok := l.NextToken()
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q",
+				i, token.AsString(tt.expectedType), token.AsString(tok.Type))
+		}
+		if tok.Value != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%v, got=%v",
+				i, tt.expectedLiteral, tok.Value)
+		}
+	}
+
+	if len(l.Errors) != 1 || l.Errors[0].Error() != "at line:8, column:1, unterminated block comment" {
+		t.Errorf("expected unterminated block comment error. got=%v", l.Errors)
+	}
+}
+
+func TestRichNumberLiterals(t *testing.T) {
+	input := "0xFF 0Xff_ff 0o17 0b1010 0b_1 1_000_000 1_000.000_1 1e1_0 9223372036854775807 0x7fffffffffffffff"
+
+	expected := []any{
+		int64(255), int64(65535), int64(15), int64(10), int64(1),
+		int64(1000000), 1000.0001, 1e10, int64(9223372036854775807), int64(9223372036854775807),
+	}
+
+	l := New(input)
+	for i, value := range expected {
+		tok := l.NextToken()
+		if tok.Value != value {
+			t.Errorf("tests[%d] - literal wrong. expected=%v, got=%v (%s)",
+				i, value, tok.Value, token.AsString(tok.Type))
+		}
+	}
+	if len(l.Errors) != 0 {
+		t.Errorf("unexpected errors: %v", l.Errors)
+	}
+}
+
+func TestInvalidNumberLiterals(t *testing.T) {
+	tests := []struct {
+		input   string
+		illegal string
+		message string
+	}{
+		{"9223372036854775808", "9223372036854775808", "integer literal 9223372036854775808 overflows int64"},
+		{"0x1_0000_0000_0000_0000", "0x1_0000_0000_0000_0000", "integer literal 0x1_0000_0000_0000_0000 overflows int64"},
+		{"0b102", "0b102", "invalid digit '2' in binary literal 0b102"},
+		{"0o8", "0o8", "invalid digit '8' in octal literal 0o8"},
+		{"0xG", "0xG", "invalid digit 'G' in hexadecimal literal 0xG"},
+		{"0x", "0x", "hexadecimal literal 0x has no digits"},
+		{"1__000", "1__000", "'_' must separate successive digits in 1__000"},
+		{"1_ ", "1_", "'_' must separate successive digits in 1_"},
+		{"1_e5", "1_e5", "'_' must separate successive digits in 1_e5"},
+		{"0x_", "0x_", "hexadecimal literal 0x_ has no digits"},
+		{"0xF_", "0xF_", "'_' must separate successive digits in 0xF_"},
+		{"1e400", "1e400", "float literal 1e400 is out of range"},
+	}
+
+	for i, tt := range tests {
+		l := New(tt.input)
+		tok := l.NextToken()
+		if tok.Type != token.ILLEGAL {
+			t.Errorf("tests[%d] - tokentype wrong. expected=ILLEGAL, got=%q (%v)",
+				i, token.AsString(tok.Type), tok.Value)
+			continue
+		}
+		if tok.Value != tt.illegal {
+			t.Errorf("tests[%d] - literal wrong. expected=%q, got=%q", i, tt.illegal, tok.Value)
+		}
+		if len(l.Errors) != 1 {
+			t.Errorf("tests[%d] - expected 1 error. got=%v", i, l.Errors)
+			continue
+		}
+		if l.Errors[0].Error() != "at line:1, column:1, "+tt.message {
+			t.Errorf("tests[%d] - wrong message. expected=%q, got=%q",
+				i, tt.message, l.Errors[0].Error())
+		}
+	}
+}
+
+func TestUnicodeSource(t *testing.T) {
+	input := "let größe = \"日本\"; _x1 + π٣\nnaïve"
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral any
+		expectedLine    int
+		expectedColumn  int
+		expectedOffset  int
+	}{
+		{token.KW_LET, "let", 1, 0, 0},
+		{token.IDENTIFIER, "größe", 1, 4, 4},
+		{token.EQUAL, nil, 1, 10, 12},
+		{token.STRING_LITERAL, "日本", 1, 12, 14},
+		{token.SEMI_COLON, nil, 1, 16, 22},
+		{token.IDENTIFIER, "_x1", 1, 18, 24},
+		{token.PLUS, nil, 1, 22, 28},
+		{token.IDENTIFIER, "π٣", 1, 24, 30},
+		{token.IDENTIFIER, "naïve", 2, 0, 35},
+		{token.EOF, nil, 2, 5, 41},
+	}
+
+	l := New(input)
+	for i, tt := range tests {
+		tok := l.NextToken()
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q",
+				i, token.AsString(tt.expectedType), token.AsString(tok.Type))
+		}
+		if tok.Value != tt.expectedLiteral {
+			t.Errorf("tests[%d] - literal wrong. expected=%v, got=%v", i, tt.expectedLiteral, tok.Value)
+		}
+		if tok.Line != tt.expectedLine || tok.Column != tt.expectedColumn || tok.Offset != tt.expectedOffset {
+			t.Errorf("tests[%d] - position wrong. expected=%d:%d (offset %d), got=%d:%d (offset %d)",
+				i, tt.expectedLine, tt.expectedColumn, tt.expectedOffset, tok.Line, tok.Column, tok.Offset)
+		}
+	}
+	if len(l.Errors) != 0 {
+		t.Errorf("unexpected errors: %v", l.Errors)
+	}
+}
+
+func TestUnicodeEscapes(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+		err      string
+	}{
+		{`"\u{1F600}"`, "😀", ""},
+		{`"\u{e9}t\u{E9}"`, "été", ""},
+		{`"\x41\x7e\xe9"`, "A~é", ""},
+		{`"\x4"`, "\uFFFD", "at line:1, column:2, \\x must be followed by two hexadecimal digits"},
+		{`"\u0041"`, "\uFFFD0041", "at line:1, column:2, \\u must be followed by {"},
+		{`"\u{}"`, "\uFFFD", "at line:1, column:2, unicode escape must have between 1 and 6 hexadecimal digits"},
+		{`"\u{41"`, "\uFFFD", "at line:1, column:2, unterminated unicode escape, expected }"},
+		{`"\u{D800}"`, "\uFFFD", "at line:1, column:2, invalid unicode code point U+D800"},
+		{`"\u{110000}"`, "\uFFFD", "at line:1, column:2, invalid unicode code point U+110000"},
+	}
+
+	for i, tt := range tests {
+		l := New(tt.input)
+		tok := l.NextToken()
+		if tok.Type != token.STRING_LITERAL {
+			t.Errorf("tests[%d] - tokentype wrong. expected=STRING, got=%q", i, token.AsString(tok.Type))
+			continue
+		}
+		if tok.Value != tt.expected {
+			t.Errorf("tests[%d] - literal wrong. expected=%q, got=%q", i, tt.expected, tok.Value)
+		}
+		if tt.err == "" && len(l.Errors) != 0 {
+			t.Errorf("tests[%d] - unexpected errors: %v", i, l.Errors)
+		}
+		if tt.err != "" && (len(l.Errors) != 1 || l.Errors[0].Error() != tt.err) {
+			t.Errorf("tests[%d] - expected error %q. got=%v", i, tt.err, l.Errors)
+		}
+	}
+}
+
+func TestInvalidUTF8(t *testing.T) {
+	l := New("a \xff b")
+	expected := []token.TokenType{token.IDENTIFIER, token.ILLEGAL, token.IDENTIFIER, token.EOF}
+	for i, tt := range expected {
+		tok := l.NextToken()
+		if tok.Type != tt {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q",
+				i, token.AsString(tt), token.AsString(tok.Type))
+		}
+	}
+	if len(l.Errors) != 1 || l.Errors[0].Error() != "at line:1, column:3, invalid UTF-8 encoding" {
+		t.Errorf("expected invalid UTF-8 error. got=%v", l.Errors)
+	}
+}
+
+func TestRawAndTripleQuotedStrings(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"`raw \\n string`", "raw \\n string"},
+		{"`spans\n  lines`", "spans\n  lines"},
+		{"`has \"quotes\"`", "has \"quotes\""},
+		{`"""one line"""`, "one line"},
+		{`"""
+    SELECT *
+      FROM t
+    WHERE x = 1
+    """`, "SELECT *\n  FROM t\nWHERE x = 1"},
+		{`"""
+    {
+      "a": 1
+    }
+"""`, "    {\n      \"a\": 1\n    }"},
+		{`"""
+      indented
+    """`, "  indented"},
+		{`"""
+    first
+
+    \tsecond\n
+    """`, "first\n\n\tsecond\n"},
+		{`"""a \""" b"""`, `a """ b`},
+		{`"""a\\"""`, `a\`},
+	}
+
+	for i, tt := range tests {
+		l := New(tt.input)
+		tok := l.NextToken()
+		if tok.Type != token.STRING_LITERAL {
+			t.Errorf("tests[%d] - tokentype wrong. expected=STRING, got=%q", i, token.AsString(tok.Type))
+			continue
+		}
+		if tok.Value != tt.expected {
+			t.Errorf("tests[%d] - literal wrong. expected=%q, got=%q", i, tt.expected, tok.Value)
+		}
+		if len(l.Errors) != 0 {
+			t.Errorf("tests[%d] - unexpected errors: %v", i, l.Errors)
+		}
+		if next := l.NextToken(); next.Type != token.EOF {
+			t.Errorf("tests[%d] - expected EOF after literal. got=%q", i, token.AsString(next.Type))
+		}
+	}
+}
+
+func TestUnterminatedMultilineStrings(t *testing.T) {
+	tests := []struct {
+		input string
+		err   string
+	}{
+		{"let s = `abc\n", "at line:1, column:9, unterminated raw string literal"},
+		{"\n  \"\"\"abc\"\"", "at line:2, column:3, unterminated triple quoted string literal"},
+		{"\"\"\"\n  bad \\q\n  \"\"\"", "at line:1, column:1, unknown escape sequence \\q"},
+	}
+
+	for i, tt := range tests {
+		l := New(tt.input)
+		for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		}
+		if len(l.Errors) != 1 || l.Errors[0].Error() != tt.err {
+			t.Errorf("tests[%d] - expected error %q. got=%v", i, tt.err, l.Errors)
+		}
+	}
+}
+
+func TestInterpolatedStrings(t *testing.T) {
+	input := `"Hi ${name}, ${len({"a": "}"})} \${x}" "${"nested ${y}"}"`
+
+	l := New(input)
+	tok := l.NextToken()
+	if tok.Type != token.INTERPOLATED_STRING {
+		t.Fatalf("tokentype wrong. expected=INTERPOLATED_STRING, got=%q", token.AsString(tok.Type))
+	}
+	parts, ok := tok.Value.([]token.StringPart)
+	if !ok {
+		t.Fatalf("value not []token.StringPart. got=%T", tok.Value)
+	}
+
+	expected := []struct {
+		text   string
+		tokens []token.TokenType
+	}{
+		{"Hi ", nil},
+		{"", []token.TokenType{token.IDENTIFIER, token.RIGHT_BRACE, token.EOF}},
+		{", ", nil},
+		{"", []token.TokenType{token.IDENTIFIER, token.LEFT_PAREN, token.LEFT_BRACE,
+			token.STRING_LITERAL, token.COLON, token.STRING_LITERAL, token.RIGHT_BRACE,
+			token.RIGHT_PAREN, token.RIGHT_BRACE, token.EOF}},
+		{" ${x}", nil},
+	}
+	if len(parts) != len(expected) {
+		t.Fatalf("wrong number of parts. expected=%d, got=%d", len(expected), len(parts))
+	}
+	for i, part := range parts {
+		if part.Text != expected[i].text {
+			t.Errorf("parts[%d] - text wrong. expected=%q, got=%q", i, expected[i].text, part.Text)
+		}
+		if len(part.Tokens) != len(expected[i].tokens) {
+			t.Errorf("parts[%d] - wrong number of tokens. expected=%d, got=%d",
+				i, len(expected[i].tokens), len(part.Tokens))
+			continue
+		}
+		for j, tt := range expected[i].tokens {
+			if part.Tokens[j].Type != tt {
+				t.Errorf("parts[%d] - tokens[%d] wrong. expected=%q, got=%q",
+					i, j, token.AsString(tt), token.AsString(part.Tokens[j].Type))
+			}
+		}
+	}
+	if name := parts[1].Tokens[0]; name.Line != 1 || name.Column != 6 {
+		t.Errorf("embedded token position wrong. expected=1:6, got=%d:%d", name.Line, name.Column)
+	}
+
+	nested := l.NextToken()
+	if nested.Type != token.INTERPOLATED_STRING {
+		t.Fatalf("tokentype wrong. expected=INTERPOLATED_STRING, got=%q", token.AsString(nested.Type))
+	}
+	inner := nested.Value.([]token.StringPart)[0].Tokens[0]
+	if inner.Type != token.INTERPOLATED_STRING {
+		t.Errorf("nested tokentype wrong. expected=INTERPOLATED_STRING, got=%q", token.AsString(inner.Type))
+	}
+	if len(l.Errors) != 0 {
+		t.Errorf("unexpected errors: %v", l.Errors)
+	}
+}
+
+func TestReaderMatchesString(t *testing.T) {
+	chunk := `/// doc
+let größe = fn(x, y) { x + y * 0x_FF - 2.5e-3 }; // comment
+let s = "a ${b} \u{1F600}" + ` + "`raw`" + ` + """
+    text
+    """;
+/* block */ if (a <= b && c != d) { [1, 2][0] } else { {"k": 1} }
+`
+	// make the source much larger than the read buffer
+	input := strings.Repeat(chunk, 200)
+
+	expected := NewReader(strings.NewReader(input), "gen.monkey")
+	actual := NewReader(iotest.OneByteReader(strings.NewReader(input)), "gen.monkey")
+	for i := 0; ; i++ {
+		want := expected.NextToken()
+		got := actual.NextToken()
+		if got.File != "gen.monkey" {
+			t.Fatalf("token[%d] - file wrong. expected=%q, got=%q", i, "gen.monkey", got.File)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("token[%d] - mismatch. expected=%s (offset %d), got=%s (offset %d)",
+				i, token.String(want), want.Offset, token.String(got), got.Offset)
+		}
+		if want.Type == token.EOF {
+			break
+		}
+	}
+	if len(expected.Errors) != 0 || len(actual.Errors) != 0 {
+		t.Errorf("unexpected errors: %v, %v", expected.Errors, actual.Errors)
+	}
+}
+
+func TestFromFilePath(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "script.monkey")
+	if err := os.WriteFile(path, []byte("let x = 1;\nx"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	l, err := FromFilePath(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var last token.Token
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		if tok.File != path {
+			t.Errorf("file wrong. expected=%q, got=%q", path, tok.File)
+		}
+		last = tok
+	}
+	if last.Line != 2 || last.Column != 0 {
+		t.Errorf("position wrong. expected=2:0, got=%d:%d", last.Line, last.Column)
+	}
+	if l.closer != nil {
+		t.Errorf("file not closed at EOF")
+	}
+
+	// the file is closed when lexing stops before its end
+	l, err = FromFilePath(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := l.closer.(*os.File)
+	l.NextToken()
+	if err := l.Close(); err != nil {
+		t.Errorf("Close failed: %s", err)
+	}
+	if f.Close() == nil {
+		t.Errorf("file not closed by Close")
+	}
+	if err := l.Close(); err != nil {
+		t.Errorf("second Close failed: %s", err)
+	}
+}
+
+func TestFileSetPositions(t *testing.T) {
+	sources := []struct {
+		name  string
+		input string
+	}{
+		{"a.monkey", "let größe = 10;\n/* ü\n*/ größe + \"ä${x}\";\n"},
+		{"b.monkey", "fn(x) {\r\n  x * 2.5\r\n}"},
+	}
+
+	fset := token.NewFileSet()
+	for _, src := range sources {
+		l := NewFileReader(fset.AddFile(src.name), strings.NewReader(src.input))
+		for tok := l.NextToken(); ; tok = l.NextToken() {
+			pos := fset.Position(tok.Pos)
+			want := token.Position{Filename: src.name, Offset: tok.Offset, Line: tok.Line, Column: tok.Column}
+			if pos != want {
+				t.Errorf("%s: position of %s wrong. expected=%s, got=%s", src.name, token.String(tok), want, pos)
+			}
+			if tok.Type == token.EOF {
+				if tok.End != tok.Pos {
+					t.Errorf("%s: EOF token not empty", src.name)
+				}
+				break
+			}
+			end := fset.Position(tok.End).Offset
+			if _, ok := tok.Value.(string); ok && tok.Type != token.STRING_LITERAL && tok.Type != token.ILLEGAL {
+				if text := src.input[tok.Offset:end]; text != tok.Value {
+					t.Errorf("%s: span of %s wrong. got=%q", src.name, token.String(tok), text)
+				}
+			}
+		}
+		if f := l.File(); f.Size() != len(src.input) {
+			t.Errorf("%s: size wrong. expected=%d, got=%d", src.name, len(src.input), f.Size())
+		}
+	}
+
+	if f := fset.File(token.Pos(1)); f == nil || f.Name() != "a.monkey" {
+		t.Errorf("file of first position wrong. got=%v", f)
+	}
+	if p := fset.Position(token.NoPos); p.IsValid() {
+		t.Errorf("NoPos decoded to %s", p)
+	}
+	// columns are shown starting at 1
+	if s := fset.Position(token.Pos(5)).String(); s != "a.monkey:1:5" {
+		t.Errorf("position string wrong. expected=%q, got=%q", "a.monkey:1:5", s)
+	}
+	if s := (token.Position{Line: 3, Column: 0}).String(); s != "3:1" {
+		t.Errorf("position string wrong. expected=%q, got=%q", "3:1", s)
+	}
+}
@@ -1,10 +1,47 @@
 package main
 
 import (
+	"fmt"
+	"github.com/px86/monkey/diag"
+	"github.com/px86/monkey/evaluator"
+	"github.com/px86/monkey/lexer"
+	"github.com/px86/monkey/object"
+	"github.com/px86/monkey/parser"
 	"github.com/px86/monkey/repl"
+	"github.com/px86/monkey/token"
 	"os"
 )
 
 func main() {
-	repl.Start(os.Stdin, os.Stdout)
+	if len(os.Args) > 1 {
+		os.Exit(runFile(os.Args[1]))
+	}
+	repl.Start(os.Stdin, os.Stdout, os.Stderr)
+}
+
+// Evaluate the monkey script at path, and return the exit status.
+func runFile(path string) int {
+	fset := token.NewFileSet()
+	l, err := lexer.FromFilePathInSet(fset, path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		return 1
+	}
+	// parsing may stop before the end of the file, after too many errors
+	defer l.Close()
+	printer := diag.NewPrinter(os.Stderr, fset)
+	p := parser.New(l)
+	prog := p.ParseProgram()
+	if len(p.Errors) > 0 {
+		for _, err := range p.Errors {
+			printer.Print(diag.FromError(err))
+		}
+		return 1
+	}
+	result := evaluator.Eval(prog, object.NewEnvironment())
+	if errobj, ok := result.(*object.Error); ok {
+		repl.PrintError(printer, fset, errobj)
+		return 1
+	}
+	return 0
 }
@@ -0,0 +1,91 @@
+package object
+
+import (
+	"hash/fnv"
+	"strings"
+)
+
+// HashKey identifies a hashable value. Two objects of the same type and
+// value produce the same HashKey.
+type HashKey struct {
+	Type  ObjectType
+	Value uint64
+}
+
+// Hashable is implemented by objects that can be used as hash keys.
+type Hashable interface {
+	Object
+	HashKey() HashKey
+}
+
+func (i *Integer) HashKey() HashKey {
+	return HashKey{Type: i.Type(), Value: uint64(i.Value)}
+}
+
+func (b *Boolean) HashKey() HashKey {
+	var value uint64
+	if b.Value {
+		value = 1
+	}
+	return HashKey{Type: b.Type(), Value: value}
+}
+
+func (s *String) HashKey() HashKey {
+	h := fnv.New64a()
+	h.Write([]byte(s.Value))
+	return HashKey{Type: s.Type(), Value: h.Sum64()}
+}
+
+type HashPair struct {
+	Key   Object
+	Value Object
+}
+
+// Hash maps hashable keys to values. Pairs are kept in insertion order,
+// so that inspecting a hash is deterministic.
+type Hash struct {
+	pairs map[HashKey]HashPair
+	keys  []HashKey
+}
+
+func NewHash() *Hash {
+	return &Hash{pairs: make(map[HashKey]HashPair)}
+}
+
+func (h *Hash) Type() ObjectType {
+	return HASH_OBJ
+}
+func (h *Hash) Inspect() string {
+	pairs := []string{}
+	for _, pair := range h.Pairs() {
+		pairs = append(pairs, pair.Key.Inspect()+": "+pair.Value.Inspect())
+	}
+	return "{" + strings.Join(pairs, ", ") + "}"
+}
+
+func (h *Hash) Get(key Hashable) (Object, bool) {
+	pair, ok := h.pairs[key.HashKey()]
+	return pair.Value, ok
+}
+
+// Set key to value. Updating an existing key keeps its original position.
+func (h *Hash) Set(key Hashable, value Object) {
+	hk := key.HashKey()
+	if _, ok := h.pairs[hk]; !ok {
+		h.keys = append(h.keys, hk)
+	}
+	h.pairs[hk] = HashPair{Key: key, Value: value}
+}
+
+func (h *Hash) Len() int {
+	return len(h.keys)
+}
+
+// Return the key/value pairs in insertion order.
+func (h *Hash) Pairs() []HashPair {
+	pairs := make([]HashPair, 0, len(h.keys))
+	for _, hk := range h.keys {
+		pairs = append(pairs, h.pairs[hk])
+	}
+	return pairs
+}
@@ -1,15 +1,32 @@
 package object
 
 import (
+	"bytes"
 	"fmt"
+	"github.com/px86/monkey/ast"
+	"github.com/px86/monkey/token"
+	"strconv"
+	"strings"
 )
 
 type ObjectType string
 
 const (
 	INTEGER_OBJ = "INTEGER"
+	FLOAT_OBJ   = "FLOAT"
 	BOOLEAN_OBJ = "Boolean"
+	STRING_OBJ  = "STRING"
 	NULL_OBJ    = "NULL"
+	ERROR_OBJ   = "ERROR"
+
+	RETURN_VALUE_OBJ = "RETURN_VALUE"
+	BREAK_OBJ        = "BREAK"
+	CONTINUE_OBJ     = "CONTINUE"
+	FUNCTION_OBJ     = "FUNCTION"
+	BUILTIN_OBJ      = "BUILTIN"
+	ARRAY_OBJ        = "ARRAY"
+	HASH_OBJ         = "HASH"
+	RANGE_OBJ        = "RANGE"
 )
 
 type Object interface {
@@ -28,6 +45,24 @@ func (i *Integer) Inspect() string {
 	return fmt.Sprintf("%d", i.Value)
 }
 
+type Float struct {
+	Value float64
+}
+
+func (f *Float) Type() ObjectType {
+	return FLOAT_OBJ
+}
+
+// Integral floats are printed with a trailing .0, so that they can be
+// told apart from integers.
+func (f *Float) Inspect() string {
+	s := strconv.FormatFloat(f.Value, 'g', -1, 64)
+	if !strings.ContainsAny(s, ".eEIN") {
+		s += ".0"
+	}
+	return s
+}
+
 type Boolean struct {
 	Value bool
 }
@@ -39,6 +74,17 @@ func (b *Boolean) Inspect() string {
 	return fmt.Sprintf("%t", b.Value)
 }
 
+type String struct {
+	Value string
+}
+
+func (s *String) Type() ObjectType {
+	return STRING_OBJ
+}
+func (s *String) Inspect() string {
+	return s.Value
+}
+
 type Null struct{}
 
 func (n *Null) Type() ObjectType {
@@ -47,3 +93,166 @@ func (n *Null) Type() ObjectType {
 func (n *Null) Inspect() string {
 	return "null"
 }
+
+type ErrorKind string
+
+const (
+	NAME_ERROR     ErrorKind = "NameError"
+	TYPE_ERROR     ErrorKind = "TypeError"
+	ARGUMENT_ERROR ErrorKind = "ArgumentError"
+	ZERO_DIVISION  ErrorKind = "ZeroDivisionError"
+	INDEX_ERROR    ErrorKind = "IndexError"
+	VALUE_ERROR    ErrorKind = "ValueError"
+)
+
+// Error is a runtime error. Evaluation stops at the first error, which
+// is returned as the result of the program.
+type Error struct {
+	Kind    ErrorKind
+	Message string
+	Token   token.Token // token of the node that failed to evaluate
+	Stack   []Frame     // function calls the error unwound through, innermost first
+}
+
+// Frame is a function call on the monkey call stack.
+type Frame struct {
+	Function string      // name of the called function, or <anonymous>
+	CallSite token.Token // token at which the function was called
+}
+
+func (e *Error) Type() ObjectType {
+	return ERROR_OBJ
+}
+func (e *Error) Inspect() string {
+	pos := token.Position{Filename: e.Token.File, Line: e.Token.Line, Column: e.Token.Column}
+	return fmt.Sprintf("%s: %s: %s", pos, e.Kind, e.Message)
+}
+
+// ReturnValue wraps the value of a return statement while it unwinds
+// through the enclosing blocks up to the function call.
+type ReturnValue struct {
+	Value Object
+}
+
+func (rv *ReturnValue) Type() ObjectType {
+	return RETURN_VALUE_OBJ
+}
+func (rv *ReturnValue) Inspect() string {
+	return rv.Value.Inspect()
+}
+
+// Break and Continue unwind through the enclosing blocks up to the loop
+// with the given label, or the innermost loop if the label is empty.
+type Break struct {
+	Label string
+}
+
+func (b *Break) Type() ObjectType {
+	return BREAK_OBJ
+}
+func (b *Break) Inspect() string {
+	return "break"
+}
+
+type Continue struct {
+	Label string
+}
+
+func (c *Continue) Type() ObjectType {
+	return CONTINUE_OBJ
+}
+func (c *Continue) Inspect() string {
+	return "continue"
+}
+
+type Function struct {
+	Name       string // name of the let binding, empty for anonymous functions
+	Parameters []*ast.Identifier
+	Body       *ast.BlockStatement
+	Env        *Environment // environment in which the function was defined
+}
+
+func (f *Function) Type() ObjectType {
+	return FUNCTION_OBJ
+}
+func (f *Function) Inspect() string {
+	var out bytes.Buffer
+	params := []string{}
+	for _, p := range f.Parameters {
+		params = append(params, p.Value)
+	}
+	out.WriteString("fn")
+	if f.Name != "" {
+		out.WriteString(" " + f.Name)
+	}
+	out.WriteString("(" + strings.Join(params, ", ") + ") ")
+	out.WriteString(f.Body.String())
+	return out.String()
+}
+
+// BuiltinFunction receives the token at which the callee of the call
+// starts, usually the name of the builtin, so that errors can point at
+// the call site.
+type BuiltinFunction func(call token.Token, args ...Object) Object
+
+type Builtin struct {
+	Name string
+	Fn   BuiltinFunction
+}
+
+func (b *Builtin) Type() ObjectType {
+	return BUILTIN_OBJ
+}
+func (b *Builtin) Inspect() string {
+	return "builtin " + b.Name
+}
+
+type Array struct {
+	Elements []Object
+}
+
+func (a *Array) Type() ObjectType {
+	return ARRAY_OBJ
+}
+func (a *Array) Inspect() string {
+	elements := []string{}
+	for _, el := range a.Elements {
+		elements = append(elements, el.Inspect())
+	}
+	return "[" + strings.Join(elements, ", ") + "]"
+}
+
+// Range is the sequence of integers from Start up to, but excluding,
+// Stop, counting by Step. Step is negative for a decreasing range, and
+// never 0.
+type Range struct {
+	Start int64
+	Stop  int64
+	Step  int64
+}
+
+func (r *Range) Type() ObjectType {
+	return RANGE_OBJ
+}
+func (r *Range) Inspect() string {
+	return fmt.Sprintf("range(%d, %d, %d)", r.Start, r.Stop, r.Step)
+}
+
+// Len returns the number of integers in r. The distances are computed
+// unsigned, so that they do not overflow, but the count itself does not
+// fit in int64 for ranges of more than math.MaxInt64 integers, and is
+// negative then. The range builtin does not create such ranges.
+func (r *Range) Len() int64 {
+	if r.Step > 0 && r.Start < r.Stop {
+		return int64(uint64(r.Stop-r.Start-1)/uint64(r.Step) + 1)
+	}
+	if r.Step < 0 && r.Start > r.Stop {
+		return int64(uint64(r.Start-r.Stop-1)/(-uint64(r.Step)) + 1)
+	}
+	return 0
+}
+
+// At returns the i-th integer of r.
+func (r *Range) At(i int64) int64 {
+	return r.Start + i*r.Step
+}
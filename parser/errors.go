@@ -0,0 +1,144 @@
+package parser
+
+import (
+	"fmt"
+
+	"github.com/px86/monkey/token"
+)
+
+// ErrorCode identifies the kind of a syntax error, so that tools can tell
+// errors apart without looking at their messages.
+type ErrorCode int
+
+const (
+	_ ErrorCode = iota
+	UNEXPECTED_TOKEN
+	INVALID_TOKEN_VALUE // the value of a token is not of the type its kind implies
+	EMPTY_INTERPOLATION
+	EXPECTED_EXPRESSION
+	INVALID_ASSIGNMENT
+	BRANCH_OUTSIDE_LOOP // break or continue outside of a loop
+	UNDEFINED_LABEL
+	INVALID_LABEL // a label not followed by a loop
+	TOO_MANY_ERRORS
+)
+
+// Number of errors after which the parser gives up, unless changed with
+// WithMaxErrors.
+const DEFAULT_MAX_ERRORS = 10
+
+// String returns the code as shown in diagnostics, like E0001.
+func (c ErrorCode) String() string {
+	return fmt.Sprintf("E%04d", int(c))
+}
+
+// SyntaxError is an error found by the parser. Pos and End delimit the
+// offending token, while Line and Column locate its start. Expected is
+// token.UNKNOWN unless a token of some specific type was expected.
+type SyntaxError struct {
+	Code     ErrorCode
+	Pos      token.Pos
+	End      token.Pos
+	Line     int
+	Column   int
+	Expected token.TokenType
+	Got      token.TokenType
+	Msg      string
+}
+
+func (e *SyntaxError) Error() string {
+	// columns are shown starting at 1, as in token.Position
+	return fmt.Sprintf("at line:%d, column:%d, %s", e.Line, e.Column+1, e.Msg)
+}
+
+// ErrorHandler is called for every error as soon as it is found, lexer
+// errors included.
+type ErrorHandler func(err error)
+
+// Option configures a Parser.
+type Option func(p *Parser)
+
+// WithErrorHandler makes the parser pass its errors to h. By default
+// errors are only collected in Parser.Errors.
+func WithErrorHandler(h ErrorHandler) Option {
+	return func(p *Parser) {
+		p.handler = h
+	}
+}
+
+// WithMaxErrors makes the parser stop after n errors, or never if n is 0.
+func WithMaxErrors(n int) Option {
+	return func(p *Parser) {
+		p.maxErrors = n
+	}
+}
+
+// Add err to the errors of p. Once maxErrors is reached, a last error
+// saying so is added, and parsing stops.
+func (p *Parser) addError(err error) {
+	if p.tooMany {
+		return
+	}
+	if p.maxErrors > 0 && len(p.Errors) == p.maxErrors {
+		p.tooMany = true
+		err = &SyntaxError{
+			Code:     TOO_MANY_ERRORS,
+			Pos:      p.curToken.Pos,
+			End:      p.curToken.End,
+			Line:     p.curToken.Line,
+			Column:   p.curToken.Column,
+			Expected: token.UNKNOWN,
+			Got:      p.curToken.Type,
+			Msg:      "too many errors",
+		}
+	}
+	p.Errors = append(p.Errors, err)
+	if p.handler != nil {
+		p.handler(err)
+	}
+}
+
+// Report a syntax error, unless the parser is recovering from an earlier
+// one. See parseStatements.
+func (p *Parser) syntaxError(err *SyntaxError) {
+	if p.panicking {
+		return
+	}
+	p.panicking = true
+	p.syntaxErrors++
+	p.addError(err)
+}
+
+// Report an error about tok.
+func (p *Parser) errorf(tok token.Token, code ErrorCode, format string, a ...any) {
+	p.syntaxError(&SyntaxError{
+		Code:     code,
+		Pos:      tok.Pos,
+		End:      tok.End,
+		Line:     tok.Line,
+		Column:   tok.Column,
+		Expected: token.UNKNOWN,
+		Got:      tok.Type,
+		Msg:      fmt.Sprintf(format, a...),
+	})
+}
+
+// Report that tok was found where a token of type expected should be.
+func (p *Parser) expectError(tok token.Token, expected token.TokenType) {
+	p.syntaxError(&SyntaxError{
+		Code:     UNEXPECTED_TOKEN,
+		Pos:      tok.Pos,
+		End:      tok.End,
+		Line:     tok.Line,
+		Column:   tok.Column,
+		Expected: expected,
+		Got:      tok.Type,
+		Msg:      fmt.Sprintf("expected %s, got=%s", token.AsString(expected), token.AsString(tok.Type)),
+	})
+}
+
+// Report that the value of tok is not of the Go type named typ.
+func (p *Parser) valueError(tok token.Token, typ string) {
+	p.errorf(tok, INVALID_TOKEN_VALUE, "%s value not of type %s. got=%T",
+		token.AsString(tok.Type), typ, tok.Value)
+}
@@ -0,0 +1,209 @@
+package parser
+
+import (
+	"github.com/px86/monkey/ast"
+	"github.com/px86/monkey/token"
+)
+
+// Expressions are parsed by precedence climbing (a Pratt parser). Every
+// token which can start an expression has a PrefixParseFn, and every
+// token which can continue one, like a binary operator or the ( of a
+// call, has an Operator. Both are looked up in tables of the parser, to
+// which embedders can add their own operators.
+
+// PrefixParseFn parses an expression starting at p.CurToken().
+type PrefixParseFn func(p *Parser) ast.Expression
+
+// InfixParseFn parses the rest of an expression starting with left, with
+// p.CurToken() being the operator.
+type InfixParseFn func(p *Parser, left ast.Expression) ast.Expression
+
+type Associativity int
+
+const (
+	LEFT_ASSOC  Associativity = iota // a - b - c is (a - b) - c
+	RIGHT_ASSOC                      // a ** b ** c is a ** (b ** c)
+)
+
+// Operator is an entry of the infix table. An operator binds tighter than
+// those of lower Precedence; see the PREC_ constants.
+type Operator struct {
+	Precedence    int
+	Associativity Associativity
+	Parse         InfixParseFn
+}
+
+func defaultPrefixParseFns() map[token.TokenType]PrefixParseFn {
+	return map[token.TokenType]PrefixParseFn{
+		token.INTEGER:             (*Parser).parseIntegerLiteral,
+		token.FLOAT:               (*Parser).parseFloatLiteral,
+		token.STRING_LITERAL:      (*Parser).parseStringLiteral,
+		token.INTERPOLATED_STRING: (*Parser).parseInterpolatedString,
+		token.IDENTIFIER:          func(p *Parser) ast.Expression { return p.parseIdentifier() },
+		token.KW_TRUE:             (*Parser).parseBoolean,
+		token.KW_FALSE:            (*Parser).parseBoolean,
+		token.KW_FUNCTION:         (*Parser).parseFunctionExpression,
+		token.KW_IF:               (*Parser).parseIfExpression,
+		token.LEFT_BRACKET:        (*Parser).parseArrayLiteral,
+		token.LEFT_BRACE:          (*Parser).parseHashLiteral,
+		token.LEFT_PAREN:          (*Parser).parseGroupedExpression,
+		token.MINUS:               ParsePrefixExpression,
+		token.EXCLAMATION:         ParsePrefixExpression,
+		token.TILDE:               ParsePrefixExpression,
+		token.ILLEGAL:             (*Parser).parseIllegal,
+	}
+}
+
+func defaultInfixOperators() map[token.TokenType]Operator {
+	return map[token.TokenType]Operator{
+		token.EQUAL:               {PREC_ASSIGN, RIGHT_ASSOC, (*Parser).parseAssignExpression},
+		token.PLUS_EQUAL:          {PREC_ASSIGN, RIGHT_ASSOC, (*Parser).parseAssignExpression},
+		token.MINUS_EQUAL:         {PREC_ASSIGN, RIGHT_ASSOC, (*Parser).parseAssignExpression},
+		token.ASTERISK_EQUAL:      {PREC_ASSIGN, RIGHT_ASSOC, (*Parser).parseAssignExpression},
+		token.SLASH_EQUAL:         {PREC_ASSIGN, RIGHT_ASSOC, (*Parser).parseAssignExpression},
+		token.PIPE_PIPE:           {PREC_LOGICAL_OR, LEFT_ASSOC, ParseInfixExpression},
+		token.AMPERSAND_AMPERSAND: {PREC_LOGICAL_AND, LEFT_ASSOC, ParseInfixExpression},
+		token.PIPE:                {PREC_BITWISE_OR, LEFT_ASSOC, ParseInfixExpression},
+		token.CARET:               {PREC_BITWISE_XOR, LEFT_ASSOC, ParseInfixExpression},
+		token.AMPERSAND:           {PREC_BITWISE_AND, LEFT_ASSOC, ParseInfixExpression},
+		token.EQUAL_EQUAL:         {PREC_EQUALS, LEFT_ASSOC, ParseInfixExpression},
+		token.EXCLAMATION_EQUAL:   {PREC_EQUALS, LEFT_ASSOC, ParseInfixExpression},
+		token.LESSER_THAN:         {PREC_LESSGREATER, LEFT_ASSOC, ParseInfixExpression},
+		token.LESSER_THAN_EQUAL:   {PREC_LESSGREATER, LEFT_ASSOC, ParseInfixExpression},
+		token.GREATER_THAN:        {PREC_LESSGREATER, LEFT_ASSOC, ParseInfixExpression},
+		token.GREATER_THAN_EQUAL:  {PREC_LESSGREATER, LEFT_ASSOC, ParseInfixExpression},
+		token.LESSER_LESSER:       {PREC_SHIFT, LEFT_ASSOC, ParseInfixExpression},
+		token.GREATER_GREATER:     {PREC_SHIFT, LEFT_ASSOC, ParseInfixExpression},
+		token.PLUS:                {PREC_SUM, LEFT_ASSOC, ParseInfixExpression},
+		token.MINUS:               {PREC_SUM, LEFT_ASSOC, ParseInfixExpression},
+		token.ASTERISK:            {PREC_PRODUCT, LEFT_ASSOC, ParseInfixExpression},
+		token.SLASH:               {PREC_PRODUCT, LEFT_ASSOC, ParseInfixExpression},
+		token.PERCENT:             {PREC_PRODUCT, LEFT_ASSOC, ParseInfixExpression},
+		token.ASTERISK_ASTERISK:   {PREC_POWER, RIGHT_ASSOC, ParseInfixExpression},
+		// calls and indexing are postfix, so that any expression can be called
+		token.LEFT_PAREN:   {PREC_CALL, LEFT_ASSOC, (*Parser).parseFunctionCall},
+		token.LEFT_BRACKET: {PREC_INDEX, LEFT_ASSOC, (*Parser).parseIndexExpression},
+	}
+}
+
+// RegisterPrefix makes fn parse the expressions starting with a token of
+// type t, replacing any previous function.
+func (p *Parser) RegisterPrefix(t token.TokenType, fn PrefixParseFn) {
+	p.prefixParseFns[t] = fn
+}
+
+// RegisterInfix makes a token of type t an infix operator, replacing any
+// previous one. Operators parsed by ParseInfixExpression evaluate to an
+// *ast.InfixExpr, which the evaluator needs to know about.
+func (p *Parser) RegisterInfix(t token.TokenType, op Operator) {
+	p.infixOperators[t] = op
+}
+
+// Operator returns the infix operator for tokens of type t.
+func (p *Parser) Operator(t token.TokenType) (Operator, bool) {
+	op, ok := p.infixOperators[t]
+	return op, ok
+}
+
+// CurToken returns the token being parsed.
+func (p *Parser) CurToken() token.Token {
+	return p.curToken
+}
+
+// Advance moves on to the next token.
+func (p *Parser) Advance() {
+	p.advance()
+}
+
+// Expect moves past the current token if it is of type t, and reports a
+// syntax error otherwise.
+func (p *Parser) Expect(t token.TokenType) bool {
+	return p.expectCurrentThenAdvance(t)
+}
+
+// ParseExpression parses an expression made of operators of higher
+// precedence than the given one.
+func (p *Parser) ParseExpression(precedence int) ast.Expression {
+	return p.parseExpression(precedence)
+}
+
+func (p *Parser) parseExpression(precedence int) ast.Expression {
+	prefix, ok := p.prefixParseFns[p.curToken.Type]
+	if !ok {
+		p.errorf(p.curToken, EXPECTED_EXPRESSION, "expected expression, got=%s",
+			token.AsString(p.curToken.Type))
+		return nil
+	}
+	left := prefix(p)
+	for !p.panicking {
+		op, ok := p.infixOperators[p.curToken.Type]
+		if !ok || op.Precedence <= precedence {
+			break
+		}
+		left = op.Parse(p, left)
+	}
+	return left
+}
+
+// ParsePrefixExpression parses a unary operator followed by its operand.
+func ParsePrefixExpression(p *Parser) ast.Expression {
+	expr := &ast.PrefixExpr{Operator: p.curToken}
+	p.advance()
+	expr.Expression = p.parseExpression(PREC_PREFIX)
+	return expr
+}
+
+// ParseInfixExpression parses the right operand of a binary operator. The
+// operand of a right associative operator may contain the same operator
+// again.
+func ParseInfixExpression(p *Parser, left ast.Expression) ast.Expression {
+	tok := p.curToken
+	op := p.infixOperators[tok.Type]
+	p.advance()
+	precedence := op.Precedence
+	if op.Associativity == RIGHT_ASSOC {
+		precedence--
+	}
+	right := p.parseExpression(precedence)
+	return &ast.InfixExpr{Left: left, Operator: tok, Right: right}
+}
+
+// Only variables and elements of arrays and hashes can be assigned to,
+// possibly in parentheses. The value of a = b = c is parsed as b = c,
+// since = is right associative.
+func (p *Parser) parseAssignExpression(target ast.Expression) ast.Expression {
+	assign := &ast.AssignExpr{Token: p.curToken, Target: target}
+	switch ast.Unparen(target).(type) {
+	case *ast.Identifier, *ast.IndexExpr:
+	default:
+		p.errorf(p.curToken, INVALID_ASSIGNMENT, "cannot assign to %s", target)
+		return nil
+	}
+	p.advance()
+	assign.Value = p.parseExpression(PREC_ASSIGN - 1)
+	return assign
+}
+
+func (p *Parser) parseGroupedExpression() ast.Expression {
+	group := &ast.GroupedExpr{Lparen: p.curToken}
+	p.advance() // move past the (
+	group.Expression = p.parseExpression(PREC_LOWEST)
+	group.Rparen = p.curToken
+	if !p.expectCurrentThenAdvance(token.RIGHT_PAREN) {
+		return nil
+	}
+	return group
+}
+
+func (p *Parser) parseBoolean() ast.Expression {
+	boolean := &ast.Boolean{Token: p.curToken, Value: p.curTokenIs(token.KW_TRUE)}
+	p.advance()
+	return boolean
+}
+
+// An illegal token was already reported by the lexer, skip it.
+func (p *Parser) parseIllegal() ast.Expression {
+	p.panicking = true
+	p.advance()
+	return nil
+}
@@ -1,23 +1,34 @@
 package parser
 
 import (
-	"errors"
-	"fmt"
 	"github.com/px86/monkey/ast"
 	"github.com/px86/monkey/lexer"
 	"github.com/px86/monkey/token"
-	"os"
+	"slices"
+	"strings"
 )
 
+// Precedence levels, from the loosest to the tightest binding, as in C.
+// The power operator binds tighter than prefix operators, so that -2 ** 2
+// is -(2 ** 2).
 const (
 	_ int = iota
 	PREC_LOWEST
+	PREC_ASSIGN
+	PREC_LOGICAL_OR
+	PREC_LOGICAL_AND
+	PREC_BITWISE_OR
+	PREC_BITWISE_XOR
+	PREC_BITWISE_AND
 	PREC_EQUALS
 	PREC_LESSGREATER
+	PREC_SHIFT
 	PREC_SUM
 	PREC_PRODUCT
 	PREC_PREFIX
+	PREC_POWER
 	PREC_CALL
+	PREC_INDEX
 )
 
 // NOTE TO MYSELF: it the responsibility of each terminal parse function to put the
@@ -25,52 +36,226 @@ const (
 // For example, if once parseLetStatement returns, the parser.curToken should point
 // at the token exactly after the semi colon.
 
-type Parser struct {
-	l      *lexer.Lexer
-	Errors []error
+// tokenSource is implemented by *lexer.Lexer, and by tokenList for the
+// expressions embedded in interpolated strings, which are already lexed.
+type tokenSource interface {
+	NextToken() token.Token
+}
+
+// tokenList returns its tokens one by one, and then the last one forever.
+type tokenList struct {
+	tokens []token.Token
+	pos    int
+}
+
+func (tl *tokenList) NextToken() token.Token {
+	tok := tl.tokens[tl.pos]
+	if tl.pos < len(tl.tokens)-1 {
+		tl.pos++
+	}
+	return tok
+}
 
+type Parser struct {
+	src       tokenSource
+	l         *lexer.Lexer // nil when parsing an embedded expression
+	lexErrors int          // number of lexer errors already moved to Errors
+	handler   ErrorHandler
+	maxErrors int     // no limit if 0
+	Errors    []error // *lexer.Error and *SyntaxError values
+
+	// Error recovery: after a syntax error, the parser is panicking until
+	// it reaches the end of the statement. Further errors are not reported
+	// meanwhile, since they are most likely caused by the first one.
+	panicking    bool
+	syntaxErrors int
+	tooMany      bool // maxErrors was reached, parsing is stopped
+	blockDepth   int  // number of enclosing block statements
+	advances     int  // number of calls to advance, to ensure progress
+
+	// the (, [ and { consumed so far and not closed yet, the innermost
+	// last. Recovery skips to the end of the groups left open by the
+	// statement with the error.
+	groups []token.TokenType
+
+	// labels of the loops enclosing the current statement, the innermost
+	// last, and an empty one for unlabeled loops. Function bodies start
+	// with none, since break and continue do not cross function calls.
+	loops []string
+
+	prefixParseFns map[token.TokenType]PrefixParseFn
+	infixOperators map[token.TokenType]Operator
+
+	prevType  token.TokenType // type of the token before curToken
 	curToken  token.Token
 	nextToken token.Token
+
+	// doc comments immediately preceding curToken and nextToken
+	curDoc  string
+	nextDoc string
 }
 
-func New(l *lexer.Lexer) *Parser {
-	p := &Parser{l: l}
+func New(l *lexer.Lexer, opts ...Option) *Parser {
+	p := &Parser{
+		src:            l,
+		l:              l,
+		maxErrors:      DEFAULT_MAX_ERRORS,
+		prefixParseFns: defaultPrefixParseFns(),
+		infixOperators: defaultInfixOperators(),
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
 
 	// Get two tokens from the lexer and populate peekToken and curToken.
 	p.advance()
 	p.advance()
 
-	// p.prefixParseFns = make(map[token.TokenType]prefixParseFn)
-	// p.registerPrefix(token.IDENTIFIER, p.parseIdentifier)
-
 	return p
 }
 
 // Moves peekToken to curToken, and fills peekToken with the next token from lexer.
 // If peekToken is EOF, the lexer is not called anymore. Further calls to p.advance
 // will have no effect. It is up to the parsing logic to gracefully handle the EOF token.
+// Doc comments are not passed on as tokens; they are attached to the
+// token that follows them instead.
 func (p *Parser) advance() {
+	p.advances++
+	p.trackGroup(p.curToken.Type)
+	p.prevType = p.curToken.Type
 	p.curToken = p.nextToken
+	p.curDoc = p.nextDoc
 	if p.nextToken.Type != token.EOF {
-		p.nextToken = p.l.NextToken()
+		docs := []string{}
+		p.nextToken = p.src.NextToken()
+		for p.nextToken.Type == token.DOC_COMMENT {
+			doc, _ := p.nextToken.Value.(string)
+			docs = append(docs, doc)
+			p.nextToken = p.src.NextToken()
+		}
+		p.nextDoc = strings.Join(docs, "\n")
+		// report lexer errors in the order in which tokens are read
+		for ; p.l != nil && p.lexErrors < len(p.l.Errors); p.lexErrors++ {
+			p.addError(p.l.Errors[p.lexErrors])
+		}
 	}
 }
 
 func (p *Parser) ParseProgram() *ast.Program {
 	program := &ast.Program{}
-	program.Statements = []ast.Statement{}
-	for p.curToken.Type != token.EOF {
+	program.Statements = p.parseStatements(token.EOF)
+	return program
+}
+
+// Parse statements up to the end token or EOF. Statements with syntax
+// errors are left out, and parsing resumes after them.
+func (p *Parser) parseStatements(end token.TokenType) []ast.Statement {
+	stmts := []ast.Statement{}
+	for !p.curTokenIs(end) && !p.curTokenIs(token.EOF) && !p.tooMany {
+		advances, errors, groups := p.advances, p.syntaxErrors, len(p.groups)
 		stmt := p.parseStatement()
-		if stmt != nil {
-			program.Statements = append(program.Statements, stmt)
+		if p.panicking {
+			p.synchronize(advances, groups)
+			p.panicking = false
+		} else if p.syntaxErrors == errors && stmt != nil {
+			stmts = append(stmts, stmt)
+		}
+		// a statement must at least consume the token it starts at
+		if p.advances == advances {
+			p.advance()
 		}
 	}
-	if len(p.Errors) > 0 {
-		for i, err := range p.Errors {
-			fmt.Fprintf(os.Stderr, "Error %2d: %s\n", i, err)
+	return stmts
+}
+
+// Skip tokens up to where parsing can resume after a syntax error: past
+// the next ;, or up to the next keyword that starts a statement or the }
+// that closes the enclosing block. Nothing is skipped if the statement
+// already ended with its ;, and at least the token at which the statement
+// started is skipped if nothing was consumed. advances and groups are the
+// values of p.advances and len(p.groups) at the start of the statement.
+func (p *Parser) synchronize(advances, groups int) {
+	p.skipGroups(groups)
+	if p.advances == advances {
+		p.advance()
+	} else if p.prevType == token.SEMI_COLON {
+		return
+	}
+	for !p.curTokenIs(token.EOF) {
+		switch p.curToken.Type {
+		case token.SEMI_COLON:
+			p.advance()
+			return
+		case token.KW_LET, token.KW_RETURN, token.KW_WHILE, token.KW_FOR,
+			token.KW_BREAK, token.KW_CONTINUE:
+			return
+		case token.RIGHT_BRACE:
+			if p.blockDepth > 0 {
+				return
+			}
 		}
+		p.advance()
 	}
-	return program
+}
+
+// Skip to the end of the groups the statement left open, those after the
+// first groups ones in p.groups, so that the rest of a group, like the )
+// of f(;), does not start a statement of its own. Skipping stops early at
+// a statement keyword outside of any { and at a closing token that does
+// not belong to the skipped groups.
+func (p *Parser) skipGroups(groups int) {
+	for len(p.groups) > groups && !p.curTokenIs(token.EOF) {
+		switch p.curToken.Type {
+		case token.KW_LET, token.KW_RETURN, token.KW_WHILE, token.KW_FOR,
+			token.KW_BREAK, token.KW_CONTINUE:
+			if p.groups[len(p.groups)-1] != token.LEFT_BRACE {
+				p.groups = p.groups[:groups]
+				return
+			}
+		case token.RIGHT_PAREN, token.RIGHT_BRACKET, token.RIGHT_BRACE:
+			if p.openGroup(p.curToken.Type, groups) < 0 {
+				p.groups = p.groups[:groups]
+				return
+			}
+		}
+		p.advance()
+	}
+}
+
+// Update p.groups for the consumed token of type t. A closing token also
+// closes the groups left open inside its own.
+func (p *Parser) trackGroup(t token.TokenType) {
+	switch t {
+	case token.LEFT_PAREN, token.LEFT_BRACKET, token.LEFT_BRACE:
+		p.groups = append(p.groups, t)
+	case token.RIGHT_PAREN, token.RIGHT_BRACKET, token.RIGHT_BRACE:
+		p.closeGroup(t)
+	}
+}
+
+// Close the innermost group that the closing token type t ends, if any.
+func (p *Parser) closeGroup(t token.TokenType) {
+	if i := p.openGroup(t, 0); i >= 0 {
+		p.groups = p.groups[:i]
+	}
+}
+
+// Index in p.groups of the innermost group, at index from or after, that
+// the closing token type t ends, or -1 if there is none.
+func (p *Parser) openGroup(t token.TokenType, from int) int {
+	open := token.LEFT_BRACE
+	switch t {
+	case token.RIGHT_PAREN:
+		open = token.LEFT_PAREN
+	case token.RIGHT_BRACKET:
+		open = token.LEFT_BRACKET
+	}
+	for i := len(p.groups) - 1; i >= from; i-- {
+		if p.groups[i] == open {
+			return i
+		}
+	}
+	return -1
 }
 
 func (p *Parser) parseStatement() ast.Statement {
@@ -79,21 +264,223 @@ func (p *Parser) parseStatement() ast.Statement {
 		return p.parseLetStatement()
 	case token.KW_RETURN:
 		return p.parseReturnStatement()
+	case token.KW_WHILE, token.KW_FOR:
+		return p.parseLoop(nil)
+	case token.KW_BREAK, token.KW_CONTINUE:
+		return p.parseBranchStatement()
+	case token.IDENTIFIER:
+		if p.nextTokenIs(token.COLON) {
+			return p.parseLabeledStatement()
+		}
+		return p.parseExpressionStatement()
 	default:
 		return p.parseExpressionStatement()
 	}
 }
 
+// A label names the loop following it, as in outer: for (...) { ... }.
+func (p *Parser) parseLabeledStatement() ast.Statement {
+	label := p.parseIdentifier()
+	if label == nil {
+		return nil
+	}
+	p.advance() // move past the :
+	if !p.curTokenIs(token.KW_WHILE) && !p.curTokenIs(token.KW_FOR) {
+		p.errorf(p.curToken, INVALID_LABEL, "expected loop after label %s, got=%s",
+			label.Value, token.AsString(p.curToken.Type))
+		return nil
+	}
+	return p.parseLoop(label)
+}
+
+// Parse the while or for loop at p.curToken, with the given label, or nil
+// if the loop is not labeled. Like an expression statement, a loop may be
+// followed by a ;.
+func (p *Parser) parseLoop(label *ast.Identifier) ast.Statement {
+	var stmt ast.Statement
+	if p.curTokenIs(token.KW_WHILE) {
+		stmt = p.parseWhileStatement(label)
+	} else {
+		stmt = p.parseForStatement(label)
+	}
+	if !p.panicking && p.curTokenIs(token.SEMI_COLON) {
+		p.advance()
+	}
+	return stmt
+}
+
+func (p *Parser) parseWhileStatement(label *ast.Identifier) ast.Statement {
+	stmt := &ast.WhileStatement{Token: p.curToken, Label: label}
+	p.advance() // move past while
+	if !p.expectCurrentThenAdvance(token.LEFT_PAREN) {
+		p.skipLoop()
+		return nil
+	}
+	stmt.Condition = p.parseExpression(PREC_LOWEST)
+	if !p.endLoopHeader() {
+		return nil
+	}
+	stmt.Body = p.parseLoopBody(label)
+	if stmt.Body == nil {
+		return nil
+	}
+	return stmt
+}
+
+// Parse either a C-style for loop, whose three clauses may each be empty,
+// or a for-in loop, which starts with an identifier followed by in.
+func (p *Parser) parseForStatement(label *ast.Identifier) ast.Statement {
+	tok := p.curToken
+	p.advance() // move past for
+	if !p.expectCurrentThenAdvance(token.LEFT_PAREN) {
+		p.skipLoop()
+		return nil
+	}
+	if p.curTokenIs(token.IDENTIFIER) && p.nextTokenIs(token.KW_IN) {
+		return p.parseForInStatement(tok, label)
+	}
+
+	stmt := &ast.ForStatement{Token: tok, Label: label}
+	switch p.curToken.Type {
+	case token.SEMI_COLON:
+		p.advance()
+	case token.KW_LET:
+		stmt.Init = p.parseLetStatement() // up to and including the ;
+	default:
+		init := &ast.ExpressionStatement{Token: p.curToken}
+		init.Expression = p.parseExpression(PREC_LOWEST)
+		stmt.Init = init
+		p.expectCurrentThenAdvance(token.SEMI_COLON)
+	}
+	if p.panicking {
+		p.skipLoop()
+		return nil
+	}
+
+	if !p.curTokenIs(token.SEMI_COLON) {
+		stmt.Condition = p.parseExpression(PREC_LOWEST)
+	}
+	if !p.expectCurrentThenAdvance(token.SEMI_COLON) || p.panicking {
+		p.skipLoop()
+		return nil
+	}
+	if !p.curTokenIs(token.RIGHT_PAREN) {
+		stmt.Update = p.parseExpression(PREC_LOWEST)
+	}
+	if !p.endLoopHeader() {
+		return nil
+	}
+	stmt.Body = p.parseLoopBody(label)
+	if stmt.Body == nil {
+		return nil
+	}
+	return stmt
+}
+
+// The parser.curToken is the loop variable, tok is the for keyword.
+func (p *Parser) parseForInStatement(tok token.Token, label *ast.Identifier) ast.Statement {
+	stmt := &ast.ForInStatement{Token: tok, Label: label}
+	stmt.Variable = p.parseIdentifier()
+	if stmt.Variable == nil {
+		p.skipLoop()
+		return nil
+	}
+	p.advance() // move past in
+	stmt.Iterable = p.parseExpression(PREC_LOWEST)
+	if !p.endLoopHeader() {
+		return nil
+	}
+	stmt.Body = p.parseLoopBody(label)
+	if stmt.Body == nil {
+		return nil
+	}
+	return stmt
+}
+
+// Check that the header of a loop ends without errors at p.curToken, a ),
+// and move past it. Otherwise the loop is skipped.
+func (p *Parser) endLoopHeader() bool {
+	if p.expectCurrentThenAdvance(token.RIGHT_PAREN) && !p.panicking {
+		return true
+	}
+	p.skipLoop()
+	return false
+}
+
+// After a syntax error in the header of a loop, skip the rest of the loop
+// up to the end of its body, so that the clauses of the header are not
+// mistaken for statements. The loop is left out, and parsing resumes
+// after it rather than at the end of the next statement. Skipping stops
+// early at the } closing the enclosing block.
+func (p *Parser) skipLoop() {
+	p.panicking = false
+	depth := 0 // of braces
+	for !p.curTokenIs(token.EOF) {
+		switch p.curToken.Type {
+		case token.LEFT_BRACE:
+			depth++
+		case token.RIGHT_BRACE:
+			if depth == 0 {
+				return
+			}
+			depth--
+			if depth == 0 {
+				p.advance()
+				return
+			}
+		}
+		p.advance()
+	}
+}
+
+// Parse the body of the loop with the given label, inside which break and
+// continue may refer to it.
+func (p *Parser) parseLoopBody(label *ast.Identifier) *ast.BlockStatement {
+	name := ""
+	if label != nil {
+		name = label.Value
+	}
+	p.loops = append(p.loops, name)
+	body := p.parseBlockStatement()
+	p.loops = p.loops[:len(p.loops)-1]
+	return body
+}
+
+// Parse break or continue, optionally followed by the label of an
+// enclosing loop.
+func (p *Parser) parseBranchStatement() ast.Statement {
+	stmt := &ast.BranchStatement{Token: p.curToken}
+	p.advance() // move past break or continue
+	if len(p.loops) == 0 {
+		p.errorf(stmt.Token, BRANCH_OUTSIDE_LOOP, "%s outside loop", token.AsString(stmt.Token.Type))
+		return nil
+	}
+	if p.curTokenIs(token.IDENTIFIER) {
+		tok := p.curToken
+		stmt.Label = p.parseIdentifier()
+		if stmt.Label == nil {
+			return nil
+		}
+		if !slices.Contains(p.loops, stmt.Label.Value) {
+			p.errorf(tok, UNDEFINED_LABEL, "undefined label %s", stmt.Label.Value)
+			return nil
+		}
+	}
+	if !p.expectCurrentThenAdvance(token.SEMI_COLON) {
+		return nil
+	}
+	return stmt
+}
+
 func (p *Parser) parseLetStatement() *ast.LetStatement {
-	stmt := &ast.LetStatement{Token: p.curToken}
+	stmt := &ast.LetStatement{Token: p.curToken, Doc: p.curDoc}
 	if !p.expectNextThenAdvance(token.IDENTIFIER) {
 		return nil
 	}
 
 	ident, ok := p.curToken.Value.(string)
 	if !ok {
-		p.Errors = append(p.Errors,
-			errors.New(fmt.Sprintf("identifier value is not string. got=%v", p.curToken.Value)))
+		p.valueError(p.curToken, "string")
 	}
 	stmt.Name = &ast.Identifier{Token: p.curToken, Value: ident}
 
@@ -131,13 +518,10 @@ func (p *Parser) parseExpressionStatement() *ast.ExpressionStatement {
 	return stmt
 }
 
-func (p *Parser) parseIntegerLiteral() *ast.IntegerLiteral {
+func (p *Parser) parseIntegerLiteral() ast.Expression {
 	value, ok := p.curToken.Value.(int64)
 	if !ok {
-		p.Errors = append(p.Errors,
-			errors.New(fmt.Sprintf("at line:%d, column:%d, %s value not of type %s. got=%T",
-				p.curToken.Line, p.curToken.Column,
-				token.AsString(p.curToken.Type), "int", p.curToken.Value)))
+		p.valueError(p.curToken, "int")
 		return nil
 	}
 	integer := &ast.IntegerLiteral{Token: p.curToken, Value: value}
@@ -145,13 +529,21 @@ func (p *Parser) parseIntegerLiteral() *ast.IntegerLiteral {
 	return integer
 }
 
-func (p *Parser) parseStringLiteral() *ast.StringLiteral {
+func (p *Parser) parseFloatLiteral() ast.Expression {
+	value, ok := p.curToken.Value.(float64)
+	if !ok {
+		p.valueError(p.curToken, "float")
+		return nil
+	}
+	float := &ast.FloatLiteral{Token: p.curToken, Value: value}
+	p.advance()
+	return float
+}
+
+func (p *Parser) parseStringLiteral() ast.Expression {
 	value, ok := p.curToken.Value.(string)
 	if !ok {
-		p.Errors = append(p.Errors,
-			errors.New(fmt.Sprintf("at line:%d, column:%d, %s value not of type %s. got=%T",
-				p.curToken.Line, p.curToken.Column,
-				token.AsString(p.curToken.Type), "string", p.curToken.Value)))
+		p.valueError(p.curToken, "string")
 		return nil
 	}
 	s := &ast.StringLiteral{Token: p.curToken, Value: value}
@@ -159,13 +551,53 @@ func (p *Parser) parseStringLiteral() *ast.StringLiteral {
 	return s
 }
 
+// Parse the parts of an interpolated string. Each embedded expression is
+// parsed by a parser of its own, whose errors are added to p.Errors.
+func (p *Parser) parseInterpolatedString() ast.Expression {
+	parts, ok := p.curToken.Value.([]token.StringPart)
+	if !ok {
+		p.valueError(p.curToken, "[]token.StringPart")
+		return nil
+	}
+	str := &ast.InterpolatedString{Token: p.curToken}
+	for _, part := range parts {
+		if part.Tokens == nil {
+			str.Parts = append(str.Parts, &ast.StringLiteral{Token: p.curToken, Value: part.Text})
+			continue
+		}
+		sub := &Parser{
+			src:            &tokenList{tokens: part.Tokens},
+			prefixParseFns: p.prefixParseFns,
+			infixOperators: p.infixOperators,
+			loops:          p.loops,
+		}
+		sub.advance()
+		sub.advance()
+		if sub.curTokenIs(token.RIGHT_BRACE) {
+			p.errorf(sub.curToken, EMPTY_INTERPOLATION, "empty interpolation")
+			continue
+		}
+		expr := sub.parseExpression(PREC_LOWEST)
+		sub.expectCurrentThenAdvance(token.RIGHT_BRACE)
+		for _, err := range sub.Errors {
+			p.addError(err)
+		}
+		// an illegal token stops the sub-parser without an error of its
+		// own, since the lexer already reported it
+		if len(sub.Errors) > 0 || sub.panicking {
+			p.panicking = true
+			p.syntaxErrors++
+		}
+		str.Parts = append(str.Parts, expr)
+	}
+	p.advance()
+	return str
+}
+
 func (p *Parser) parseIdentifier() *ast.Identifier {
 	id, ok := p.curToken.Value.(string)
 	if !ok {
-		p.Errors = append(p.Errors,
-			errors.New(fmt.Sprintf("at line:%d, column:%d, %s value not of type %s. got=%T",
-				p.curToken.Line, p.curToken.Column,
-				token.AsString(p.curToken.Type), "string", p.curToken.Value)))
+		p.valueError(p.curToken, "string")
 		return nil
 	}
 	identifier := &ast.Identifier{Token: p.curToken, Value: id}
@@ -173,7 +605,7 @@ func (p *Parser) parseIdentifier() *ast.Identifier {
 	return identifier
 }
 
-func (p *Parser) parseFunctionExpression() *ast.FunctionExpr {
+func (p *Parser) parseFunctionExpression() ast.Expression {
 	fexpr := &ast.FunctionExpr{Token: p.curToken} // fn keyword
 	p.advance()
 	if !p.expectCurrentThenAdvance(token.LEFT_PAREN) {
@@ -181,125 +613,120 @@ func (p *Parser) parseFunctionExpression() *ast.FunctionExpr {
 	}
 	// args
 	for !p.curTokenIs(token.RIGHT_PAREN) && !p.curTokenIs(token.EOF) {
+		if !p.curTokenIs(token.IDENTIFIER) {
+			p.expectError(p.curToken, token.IDENTIFIER)
+			return nil
+		}
 		fexpr.Args = append(fexpr.Args, p.parseIdentifier())
-		if p.curTokenIs(token.COMMA) {
-			p.advance()
+		if !p.curTokenIs(token.COMMA) {
+			break
 		}
+		p.advance()
 	}
 
 	if !p.expectCurrentThenAdvance(token.RIGHT_PAREN) {
 		return nil
 	}
 
+	loops := p.loops
+	p.loops = nil
 	fexpr.Body = p.parseBlockStatement()
+	p.loops = loops
 
 	return fexpr
 }
 
-func (p *Parser) parseFunctionCall() *ast.FunctionCall {
-	ident := p.parseIdentifier()
-	if ident == nil {
+// Parse the argument list of a call to function. The parser.curToken is
+// the ( following the callee expression.
+func (p *Parser) parseFunctionCall(function ast.Expression) ast.Expression {
+	fcall := &ast.FunctionCall{Token: p.curToken, Function: function}
+	p.advance() // move past the (
+	args, end, ok := p.parseExpressionList(token.RIGHT_PAREN)
+	if !ok {
 		return nil
 	}
-	var fcall *ast.FunctionCall
-	if p.expectCurrentThenAdvance(token.LEFT_PAREN) {
-		fcall = &ast.FunctionCall{Name: ident}
-		for !p.curTokenIs(token.RIGHT_PAREN) {
-			fcall.Args = append(fcall.Args, p.parseExpression(PREC_LOWEST))
-			if p.curTokenIs(token.COMMA) {
-				p.advance()
-			}
-		}
-		p.advance()
-	}
+	fcall.Args, fcall.Rparen = args, end
 	return fcall
 }
 
-func (p *Parser) parseExpression(basePrecedence int) ast.Expression {
-	left := p.parseLeaf()
-	for {
-		tok := p.curToken
-		prec := precOf(tok.Type)
-		if !isBinaryOperator(tok.Type) || prec <= basePrecedence {
+func (p *Parser) parseArrayLiteral() ast.Expression {
+	array := &ast.ArrayLiteral{Token: p.curToken}
+	p.advance() // move past the [
+	elements, end, ok := p.parseExpressionList(token.RIGHT_BRACKET)
+	if !ok {
+		return nil
+	}
+	array.Elements, array.Rbracket = elements, end
+	return array
+}
+
+// A { in expression position always starts a hash literal, since block
+// statements only follow if, else, fn and loops, which parse them directly.
+func (p *Parser) parseHashLiteral() ast.Expression {
+	hash := &ast.HashLiteral{Token: p.curToken}
+	p.advance() // move past the {
+	for !p.curTokenIs(token.RIGHT_BRACE) && !p.curTokenIs(token.EOF) {
+		key := p.parseExpression(PREC_LOWEST)
+		if !p.expectCurrentThenAdvance(token.COLON) {
+			return nil
+		}
+		value := p.parseExpression(PREC_LOWEST)
+		hash.Pairs = append(hash.Pairs, ast.HashPair{Key: key, Value: value})
+		if !p.curTokenIs(token.COMMA) {
 			break
 		}
 		p.advance()
-		right := p.parseExpression(prec)
-		left = &ast.InfixExpr{Left: left, Operator: tok, Right: right}
 	}
-	return left
+	hash.Rbrace = p.curToken
+	if !p.expectCurrentThenAdvance(token.RIGHT_BRACE) {
+		return nil
+	}
+	return hash
 }
 
-func (p *Parser) parseLeaf() ast.Expression {
-	var leaf ast.Expression
-
-	switch p.curToken.Type {
-	case token.INTEGER:
-		leaf = p.parseIntegerLiteral()
-	case token.STRING_LITERAL:
-		leaf = p.parseStringLiteral()
-	case token.KW_FUNCTION:
-		leaf = p.parseFunctionExpression()
-	case token.KW_IF:
-		leaf = p.parseIfExpression()
-	case token.IDENTIFIER:
-		if p.nextTokenIs(token.LEFT_PAREN) {
-			leaf = p.parseFunctionCall()
-		} else {
-			leaf = p.parseIdentifier()
+// Parse the index or the slice following left. The parser.curToken is the [.
+func (p *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
+	tok := p.curToken
+	p.advance() // move past the [
+	var index ast.Expression
+	if !p.curTokenIs(token.COLON) {
+		index = p.parseExpression(PREC_LOWEST)
+	}
+	if p.curTokenIs(token.COLON) {
+		p.advance() // move past the :
+		slice := &ast.SliceExpr{Token: tok, Left: left, Low: index}
+		if !p.curTokenIs(token.RIGHT_BRACKET) {
+			slice.High = p.parseExpression(PREC_LOWEST)
 		}
-	case token.MINUS:
-		t := &ast.PrefixExpr{Operator: p.curToken}
-		p.advance()
-		t.Expression = p.parseExpression(PREC_LOWEST)
-		leaf = t
-
-	case token.EXCLAMATION:
-		t := &ast.PrefixExpr{Operator: p.curToken}
-		p.advance()
-		t.Expression = p.parseExpression(PREC_LOWEST)
-		leaf = t
-
-	case token.LEFT_PAREN:
-		p.advance() // move past the (
-		leaf = p.parseExpression(PREC_LOWEST)
-		p.expectCurrentThenAdvance(token.RIGHT_PAREN)
-
-	case token.KW_TRUE:
-		leaf = &ast.Boolean{Token: p.curToken, Value: true}
-		p.advance()
-
-	case token.KW_FALSE:
-		leaf = &ast.Boolean{Token: p.curToken, Value: false}
-		p.advance()
+		slice.Rbracket = p.curToken
+		if !p.expectCurrentThenAdvance(token.RIGHT_BRACKET) {
+			return nil
+		}
+		return slice
 	}
-
-	return leaf
+	rbracket := p.curToken
+	if !p.expectCurrentThenAdvance(token.RIGHT_BRACKET) {
+		return nil
+	}
+	return &ast.IndexExpr{Token: tok, Left: left, Index: index, Rbracket: rbracket}
 }
 
-func precOf(toktype token.TokenType) int {
-	prec := PREC_LOWEST
-	switch toktype {
-	case token.PLUS:
-		prec = PREC_SUM
-	case token.MINUS:
-		prec = PREC_SUM
-	case token.ASTERISK:
-		prec = PREC_PRODUCT
-	case token.SLASH:
-		prec = PREC_PRODUCT
-	case token.LESSER_THAN:
-		prec = PREC_LESSGREATER
-	case token.LESSER_THAN_EQUAL:
-		prec = PREC_LESSGREATER
-	case token.GREATER_THAN:
-		prec = PREC_LESSGREATER
-	case token.GREATER_THAN_EQUAL:
-		prec = PREC_LESSGREATER
-	case token.KW_FUNCTION:
-		prec = PREC_CALL
+// Parse comma separated expressions up to and including the end token,
+// which is returned as well. Trailing comma is allowed.
+func (p *Parser) parseExpressionList(end token.TokenType) ([]ast.Expression, token.Token, bool) {
+	list := []ast.Expression{}
+	for !p.curTokenIs(end) && !p.curTokenIs(token.EOF) {
+		list = append(list, p.parseExpression(PREC_LOWEST))
+		if !p.curTokenIs(token.COMMA) {
+			break
+		}
+		p.advance()
+	}
+	endToken := p.curToken
+	if !p.expectCurrentThenAdvance(end) {
+		return nil, endToken, false
 	}
-	return prec
+	return list, endToken, true
 }
 
 func (p *Parser) curTokenIs(t token.TokenType) bool {
@@ -314,11 +741,11 @@ func (p *Parser) expectNextThenAdvance(t token.TokenType) bool {
 	if p.nextToken.Type == t {
 		p.advance()
 		return true
+	} else if p.nextToken.Type == token.ILLEGAL {
+		p.panicking = true
+		return false
 	} else {
-		p.Errors = append(p.Errors,
-			errors.New(fmt.Sprintf("at line:%d, column:%d, expected %s, got=%s",
-				p.nextToken.Line, p.nextToken.Column,
-				token.AsString(t), token.AsString(p.nextToken.Type))))
+		p.expectError(p.nextToken, t)
 		return false
 	}
 }
@@ -327,51 +754,21 @@ func (p *Parser) expectCurrentThenAdvance(t token.TokenType) bool {
 	if p.curToken.Type == t {
 		p.advance()
 		return true
-	} else {
-		p.Errors = append(p.Errors,
-			errors.New(fmt.Sprintf("at line:%d, column:%d, expected %s, got=%s",
-				p.nextToken.Line, p.nextToken.Column,
-				token.AsString(t), token.AsString(p.nextToken.Type))))
+	} else if p.curToken.Type == token.ILLEGAL {
+		p.panicking = true
 		return false
-	}
-}
-
-func isBinaryOperator(toktype token.TokenType) bool {
-	switch toktype {
-	case token.PLUS:
-		return true
-	case token.MINUS:
-		return true
-	case token.ASTERISK:
-		return true
-	case token.SLASH:
-		return true
-	case token.LESSER_THAN:
-		return true
-	case token.LESSER_THAN_EQUAL:
-		return true
-	case token.GREATER_THAN:
-		return true
-	case token.GREATER_THAN_EQUAL:
-		return true
-	case token.EQUAL:
-		return true
-	case token.EQUAL_EQUAL:
-		return true
-	case token.AMPERSAND:
-		return true
-	case token.AMPERSAND_AMPERSAND:
-		return true
-	case token.PIPE:
-		return true
-	case token.PIPE_PIPE:
-		return true
-	default:
+	} else {
+		// a missing closing token ends its group all the same, unless
+		// the error only follows from an earlier one
+		if !p.panicking {
+			p.closeGroup(t)
+		}
+		p.expectError(p.curToken, t)
 		return false
 	}
 }
 
-func (p *Parser) parseIfExpression() *ast.IfExpression {
+func (p *Parser) parseIfExpression() ast.Expression {
 	ifexpr := &ast.IfExpression{Token: p.curToken}
 	p.advance()
 	p.expectCurrentThenAdvance(token.LEFT_PAREN)
@@ -387,10 +784,15 @@ func (p *Parser) parseIfExpression() *ast.IfExpression {
 
 func (p *Parser) parseBlockStatement() *ast.BlockStatement {
 	bstmt := &ast.BlockStatement{Token: p.curToken} // {
-	p.advance()
-	for !(p.curTokenIs(token.RIGHT_BRACE) || p.curTokenIs(token.EOF)) {
-		bstmt.Statements = append(bstmt.Statements, p.parseStatement())
+	if !p.expectCurrentThenAdvance(token.LEFT_BRACE) {
+		return nil
+	}
+	p.blockDepth++
+	bstmt.Statements = p.parseStatements(token.RIGHT_BRACE)
+	p.blockDepth--
+	bstmt.Rbrace = p.curToken
+	if !p.expectCurrentThenAdvance(token.RIGHT_BRACE) {
+		return nil
 	}
-	p.expectCurrentThenAdvance(token.RIGHT_BRACE)
 	return bstmt
 }
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"github.com/px86/monkey/ast"
 	"github.com/px86/monkey/lexer"
+	"github.com/px86/monkey/token"
 	"testing"
 )
 
@@ -33,8 +34,8 @@ func TestReturnStatements(t *testing.T) {
 	if !ok {
 		t.Fatalf("stmt.ReturnValue not *ast.FunctionCall. got=%T", stmt.ReturnValue)
 	}
-	if fcall.Name.Value != "foo" {
-		t.Fatalf("function name not %q. got=%q", "foo", fcall.Name.Value)
+	if fcall.Function.String() != "foo" {
+		t.Fatalf("function name not %q. got=%q", "foo", fcall.Function.String())
 	}
 }
 
@@ -105,6 +106,8 @@ func TestPrefixExpressions(t *testing.T) {
 		{"-1;", "(- 1)"},
 		{"-foo(x, y);", "(- (foo x y))"},
 		{"!foo;", "(! foo)"},
+		{"~x;", "(~ x)"},
+		{"-2 ** 2;", "(- (** 2 2))"},
 	}
 
 	for i, testcase := range input {
@@ -146,6 +149,21 @@ func TestBinaryExpressions(t *testing.T) {
 		{"1 + 2 * 3 + 4/2 - 1;", "(- (+ (+ 1 (* 2 3)) (/ 4 2)) 1)"},
 		{"bar() * foo + 3;", "(+ (* (bar) foo) 3)"},
 		{"2 * (3 + 4);", "(* 2 (+ 3 4))"},
+		{"-1 + 2;", "(+ (- 1) 2)"},
+		{"1.5 * 2e3 - 0.25;", "(- (* 1.5 2000) 0.25)"},
+		{"1 + 2 == 3;", "(== (+ 1 2) 3)"},
+		{"1 < 2 != !x;", "(!= (< 1 2) (! x))"},
+		{"a || b && c;", "(|| a (&& b c))"},
+		{"a && b || c && d;", "(|| (&& a b) (&& c d))"},
+		{"a == b && c != d;", "(&& (== a b) (!= c d))"},
+		{"a | b ^ c & d;", "(| a (^ b (& c d)))"},
+		{"a & b == c;", "(& a (== b c))"},
+		{"a < b << c;", "(< a (<< b c))"},
+		{"1 << 2 + 3 >> 4;", "(>> (<< 1 (+ 2 3)) 4)"},
+		{"a + b % c * d;", "(+ a (* (% b c) d))"},
+		{"2 ** 3 ** 2;", "(** 2 (** 3 2))"},
+		{"2 ** -1 * 3;", "(* (** 2 (- 1)) 3)"},
+		{"~a & b;", "(& (~ a) b)"},
 	}
 
 	for i, testcase := range input {
@@ -246,8 +264,8 @@ func TestFunctionCall(t *testing.T) {
 			t.Fatalf("[%d] exp not *ast.Functioncall. got=%T", i, expStmt.Expression)
 		}
 
-		if fc.Name.Value != tt.FunctionName {
-			t.Fatalf("[%d] function name not %q. got=%q", i, tt.FunctionName, fc.Name.Value)
+		if fc.Function.String() != tt.FunctionName {
+			t.Fatalf("[%d] function name not %q. got=%q", i, tt.FunctionName, fc.Function.String())
 		}
 
 		if len(fc.Args) != len(tt.Args) {
@@ -258,6 +276,103 @@ func TestFunctionCall(t *testing.T) {
 
 }
 
+func TestCallExpressions(t *testing.T) {
+
+	input := []struct {
+		expr string
+		tree string
+	}{
+		{"fn(x) { x }(5);", "((fn (x) (block x)) 5)"},
+		{"makeAdder(1)(2);", "((makeAdder 1) 2)"},
+		{"(f)(x);", "(f x)"},
+		{"a + b(c) * d;", "(+ a (* (b c) d))"},
+		{"-f(1)(2);", "(- ((f 1) 2))"},
+		{"fns[0](1);", "((index fns 0) 1)"},
+	}
+
+	for i, testcase := range input {
+
+		p := New(lexer.New(testcase.expr))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("[TC %d] program does not contain 1 statement. got=%d",
+				i, len(program.Statements))
+		}
+		if program.Statements[0].String() != testcase.tree {
+			t.Errorf("[TC %d] AST string didn't match. expected=%q, got=%q",
+				i, testcase.tree, program.Statements[0].String())
+		}
+	}
+}
+
+func TestArrayAndIndexExpressions(t *testing.T) {
+
+	input := []struct {
+		expr string
+		tree string
+	}{
+		{"[];", "(array)"},
+		{"[1, 2 * 2, 3 + 3];", "(array 1 (* 2 2) (+ 3 3))"},
+		{"[1, 2,];", "(array 1 2)"},
+		{"a[1 + 1];", "(index a (+ 1 1))"},
+		{"a * [1, 2][b * c] * d;", "(* (* a (index (array 1 2) (* b c))) d)"},
+		{"f(a[0], b)[1][2];", "(index (index (f (index a 0) b) 1) 2)"},
+		{"s[1:2];", "(slice s 1 2)"},
+		{"s[:n - 1];", "(slice s _ (- n 1))"},
+		{"s[1:];", "(slice s 1 _)"},
+		{"s[:];", "(slice s _ _)"},
+	}
+
+	for i, testcase := range input {
+
+		p := New(lexer.New(testcase.expr))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("[TC %d] program does not contain 1 statement. got=%d",
+				i, len(program.Statements))
+		}
+		if program.Statements[0].String() != testcase.tree {
+			t.Errorf("[TC %d] AST string didn't match. expected=%q, got=%q",
+				i, testcase.tree, program.Statements[0].String())
+		}
+	}
+}
+
+func TestHashLiterals(t *testing.T) {
+
+	input := []struct {
+		expr string
+		tree string
+	}{
+		{"{};", "(hash)"},
+		{`{"one": 1, "two": 1 + 1};`, `(hash ("one" 1) ("two" (+ 1 1)))`},
+		{`{"name": "x", 1: true,};`, `(hash ("name" "x") (1 true))`},
+		{`let h = {a: [1], "b": {}};`, `(let h (hash (a (array 1)) ("b" (hash))))`},
+		{`{"k": 1}["k"];`, `(index (hash ("k" 1)) "k")`},
+		{`if (x) { {1: 2} }`, `(if x (block (hash (1 2))))`},
+	}
+
+	for i, testcase := range input {
+
+		p := New(lexer.New(testcase.expr))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("[TC %d] program does not contain 1 statement. got=%d",
+				i, len(program.Statements))
+		}
+		if program.Statements[0].String() != testcase.tree {
+			t.Errorf("[TC %d] AST string didn't match. expected=%q, got=%q",
+				i, testcase.tree, program.Statements[0].String())
+		}
+	}
+}
+
 func TestIfExpression(t *testing.T) {
 	input := "if (x < y) { y - x } else { x - y }"
 	l := lexer.New(input)
@@ -282,3 +397,517 @@ func TestIfExpression(t *testing.T) {
 		t.Fatalf("condition ast not %v. got=%v", "(< x y)", ifexpr.Condition.String())
 	}
 }
+
+func TestLexerErrorsAreReported(t *testing.T) {
+	input := "let x = 1 @ 2;\n\"abc"
+
+	p := New(lexer.New(input))
+	p.ParseProgram()
+
+	expected := []string{
+		"at line:1, column:11, unexpected character '@'",
+		"at line:2, column:1, unterminated string literal",
+	}
+	if len(p.Errors) != len(expected) {
+		t.Fatalf("wrong number of errors. expected=%d, got=%d (%v)",
+			len(expected), len(p.Errors), p.Errors)
+	}
+	for i, err := range p.Errors {
+		if err.Error() != expected[i] {
+			t.Errorf("errors[%d] - wrong message. expected=%q, got=%q", i, expected[i], err.Error())
+		}
+	}
+}
+
+func TestDocComments(t *testing.T) {
+	input := `/// The answer.
+let answer = 42;
+
+/// Adds x and y.
+///
+/// Both must be integers.
+let add = fn(x, y) { x + y };
+
+/// Not attached to a let statement.
+add(1, 2);
+let undocumented = 1;`
+
+	p := New(lexer.New(input))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 4 {
+		t.Fatalf("program does not contain 4 statements. got=%d", len(program.Statements))
+	}
+
+	expected := map[int]string{
+		0: "The answer.",
+		1: "Adds x and y.\n\nBoth must be integers.",
+		3: "",
+	}
+	for i, doc := range expected {
+		lstmt, ok := program.Statements[i].(*ast.LetStatement)
+		if !ok {
+			t.Fatalf("statement[%d] not *ast.LetStatement. got=%T", i, program.Statements[i])
+		}
+		if lstmt.Doc != doc {
+			t.Errorf("statement[%d] - wrong doc. expected=%q, got=%q", i, doc, lstmt.Doc)
+		}
+	}
+}
+
+func TestInterpolatedStrings(t *testing.T) {
+	input := []struct {
+		expr string
+		tree string
+	}{
+		{`"Hello ${name}!";`, `(interp "Hello " name "!")`},
+		{`"${a + b * 2}";`, `(interp (+ a (* b 2)))`},
+		{`"${len(items)} items" + s;`, `(+ (interp (len items) " items") s)`},
+		{`"${"in ${x}"}";`, `(interp (interp "in " x))`},
+	}
+
+	for i, testcase := range input {
+		p := New(lexer.New(testcase.expr))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("[TC %d] program does not contain 1 statement. got=%d",
+				i, len(program.Statements))
+		}
+		if program.Statements[0].String() != testcase.tree {
+			t.Errorf("[TC %d] AST string didn't match. expected=%q, got=%q",
+				i, testcase.tree, program.Statements[0].String())
+		}
+	}
+}
+
+func TestInterpolationErrors(t *testing.T) {
+	input := []struct {
+		expr string
+		err  string
+	}{
+		{`"a ${}";`, "at line:1, column:6, empty interpolation"},
+		{`"a ${x y}";`, "at line:1, column:8, expected }, got=IDENTIFIER"},
+		{`"a ${@} b";`, "at line:1, column:6, unexpected character '@'"},
+		{`"${1 +}";`, "at line:1, column:7, expected expression, got=}"},
+		{`"${1 @}";`, "at line:1, column:6, unexpected character '@'"},
+	}
+
+	for i, testcase := range input {
+		p := New(lexer.New(testcase.expr))
+		program := p.ParseProgram()
+		if len(p.Errors) != 1 || p.Errors[0].Error() != testcase.err {
+			t.Errorf("[TC %d] expected error %q. got=%v", i, testcase.err, p.Errors)
+		}
+		if program.String() != "(prog )" {
+			t.Errorf("[TC %d] statement with errors kept. got=%q", i, program.String())
+		}
+	}
+}
+
+func TestSyntaxErrors(t *testing.T) {
+	input := "let x 5;\nlet y = \"${}\";\n@"
+
+	var handled []error
+	p := New(lexer.New(input), WithErrorHandler(func(err error) {
+		handled = append(handled, err)
+	}))
+	p.ParseProgram()
+
+	expected := []struct {
+		code     ErrorCode
+		line     int
+		column   int
+		expected token.TokenType
+		got      token.TokenType
+	}{
+		{UNEXPECTED_TOKEN, 1, 6, token.EQUAL, token.INTEGER},
+		{EMPTY_INTERPOLATION, 2, 11, token.UNKNOWN, token.RIGHT_BRACE},
+	}
+	// the last error is the lexer's
+	if len(p.Errors) != len(expected)+1 {
+		t.Fatalf("wrong number of errors. expected=%d, got=%d (%v)",
+			len(expected)+1, len(p.Errors), p.Errors)
+	}
+	for i, tt := range expected {
+		err, ok := p.Errors[i].(*SyntaxError)
+		if !ok {
+			t.Fatalf("errors[%d] not *SyntaxError. got=%T", i, p.Errors[i])
+		}
+		if err.Code != tt.code || err.Line != tt.line || err.Column != tt.column ||
+			err.Expected != tt.expected || err.Got != tt.got {
+			t.Errorf("errors[%d] wrong. expected=%v, got=%+v", i, tt, err)
+		}
+		if !err.Pos.IsValid() || (err.End <= err.Pos && err.Got != token.EOF) {
+			t.Errorf("errors[%d] - span wrong. got=%d..%d", i, err.Pos, err.End)
+		}
+	}
+	if _, ok := p.Errors[2].(*lexer.Error); !ok {
+		t.Errorf("errors[2] not *lexer.Error. got=%T", p.Errors[2])
+	}
+
+	if len(handled) != len(p.Errors) {
+		t.Fatalf("handler called %d times, expected %d", len(handled), len(p.Errors))
+	}
+	for i := range handled {
+		if handled[i] != p.Errors[i] {
+			t.Errorf("handled[%d] wrong. expected=%v, got=%v", i, p.Errors[i], handled[i])
+		}
+	}
+	if UNEXPECTED_TOKEN.String() != "E0001" {
+		t.Errorf("code wrong. got=%s", UNEXPECTED_TOKEN)
+	}
+}
+
+func TestErrorRecovery(t *testing.T) {
+	input := []struct {
+		src    string
+		errors []string
+		tree   string
+	}{
+		{
+			"let x = 5 );\nlet y = 1;",
+			[]string{"at line:1, column:11, expected ;, got=)"},
+			"(prog (let y 1))",
+		},
+		{
+			"let a = 1 + ;\nlet b = 2;",
+			[]string{"at line:1, column:13, expected expression, got=;"},
+			"(prog (let b 2))",
+		},
+		{
+			"let y = [1, 2;\nfoo(1, 2\nlet z = 3;",
+			[]string{
+				"at line:1, column:14, expected ], got=;",
+				"at line:3, column:1, expected ), got=let",
+			},
+			"(prog (let z 3))",
+		},
+		{
+			"let f = fn(x) { x + ; x };\nf(1);",
+			[]string{"at line:1, column:21, expected expression, got=;"},
+			"(prog (f 1))",
+		},
+		{
+			"let g = fn(a, 1) { a };\n}\nlet h = {\"a\": 1 2};\ng(1);",
+			[]string{
+				"at line:1, column:15, expected IDENTIFIER, got=INTEGER",
+				"at line:2, column:1, expected expression, got=}",
+				"at line:3, column:17, expected }, got=INTEGER",
+			},
+			"(prog (g 1))",
+		},
+		{
+			"if (x { 1 } else { 2 };\nfoo(",
+			[]string{
+				"at line:1, column:7, expected ), got={",
+				"at line:2, column:5, expected ), got=EOF",
+			},
+			"(prog )",
+		},
+		{
+			"if (x) 1;\nlet = 2;\nreturn ;",
+			[]string{
+				"at line:1, column:8, expected {, got=INTEGER",
+				"at line:2, column:5, expected IDENTIFIER, got==",
+				"at line:3, column:8, expected expression, got=;",
+			},
+			"(prog )",
+		},
+		{
+			"f(;)",
+			[]string{"at line:1, column:3, expected expression, got=;"},
+			"(prog )",
+		},
+		{
+			"f([1, ;], (2), {\"a\": [;]});\ng(1);",
+			[]string{"at line:1, column:7, expected expression, got=;"},
+			"(prog (g 1))",
+		},
+		{
+			"let a = f(1 + ;\nlet b = 2;",
+			[]string{"at line:1, column:15, expected expression, got=;"},
+			"(prog (let b 2))",
+		},
+		{
+			"let c = fn() { g(; };\nc();",
+			[]string{"at line:1, column:18, expected expression, got=;"},
+			"(prog (c))",
+		},
+	}
+
+	for i, testcase := range input {
+		p := New(lexer.New(testcase.src))
+		program := p.ParseProgram()
+		if len(p.Errors) != len(testcase.errors) {
+			t.Errorf("[TC %d] wrong number of errors. expected=%d, got=%d (%v)",
+				i, len(testcase.errors), len(p.Errors), p.Errors)
+			continue
+		}
+		for j, err := range p.Errors {
+			if err.Error() != testcase.errors[j] {
+				t.Errorf("[TC %d] errors[%d] wrong. expected=%q, got=%q",
+					i, j, testcase.errors[j], err.Error())
+			}
+		}
+		if program.String() != testcase.tree {
+			t.Errorf("[TC %d] AST string didn't match. expected=%q, got=%q",
+				i, testcase.tree, program.String())
+		}
+	}
+}
+
+func TestTooManyErrors(t *testing.T) {
+	input := bytes.Repeat([]byte("let = 1;\n"), 20)
+
+	p := New(lexer.New(string(input)))
+	p.ParseProgram()
+	if len(p.Errors) != DEFAULT_MAX_ERRORS+1 {
+		t.Fatalf("wrong number of errors. expected=%d, got=%d", DEFAULT_MAX_ERRORS+1, len(p.Errors))
+	}
+	last, ok := p.Errors[DEFAULT_MAX_ERRORS].(*SyntaxError)
+	if !ok || last.Code != TOO_MANY_ERRORS {
+		t.Errorf("last error wrong. got=%v", p.Errors[DEFAULT_MAX_ERRORS])
+	}
+
+	p = New(lexer.New(string(input)), WithMaxErrors(0))
+	p.ParseProgram()
+	if len(p.Errors) != 20 {
+		t.Errorf("wrong number of errors without limit. expected=20, got=%d", len(p.Errors))
+	}
+}
+
+func TestAssignExpressions(t *testing.T) {
+	input := []struct {
+		expr string
+		tree string
+	}{
+		{"x = 1;", "(= x 1)"},
+		{"x = y = 1 + 2;", "(= x (= y (+ 1 2)))"},
+		{"x += y -= 2;", "(+= x (-= y 2))"},
+		{"a[0] *= 2;", "(*= (index a 0) 2)"},
+		{`h["k"] /= n || m;`, `(/= (index h "k") (|| n m))`},
+		{"f(x = 1);", "(f (= x 1))"},
+		{"(x) += (1);", "(+= x 1)"},
+	}
+
+	for i, testcase := range input {
+		p := New(lexer.New(testcase.expr))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+		if len(program.Statements) != 1 {
+			t.Fatalf("[TC %d] program does not contain 1 statement. got=%d",
+				i, len(program.Statements))
+		}
+		if program.Statements[0].String() != testcase.tree {
+			t.Errorf("[TC %d] AST string didn't match. expected=%q, got=%q",
+				i, testcase.tree, program.Statements[0].String())
+		}
+	}
+
+	invalid := []struct {
+		expr string
+		err  string
+	}{
+		{"1 = 2;", "at line:1, column:3, cannot assign to 1"},
+		{"f() = 2;", "at line:1, column:5, cannot assign to (f)"},
+		{"a + b = 2;", "at line:1, column:7, cannot assign to (+ a b)"},
+		{"a[1:] += 2;", "at line:1, column:7, cannot assign to (slice a 1 _)"},
+	}
+	for i, testcase := range invalid {
+		p := New(lexer.New(testcase.expr))
+		p.ParseProgram()
+		if len(p.Errors) != 1 || p.Errors[0].Error() != testcase.err {
+			t.Errorf("[TC %d] expected error %q. got=%v", i, testcase.err, p.Errors)
+		}
+	}
+}
+
+func TestLoops(t *testing.T) {
+	input := []struct {
+		expr string
+		tree string
+	}{
+		{"while (x < 10) { x += 1; }", "(while (< x 10) (block (+= x 1)))"},
+		{"while (true) { break; };", "(while true (block (break)))"},
+		{"for (let i = 0; i < n; i += 1) { continue; }",
+			"(for (let i 0) (< i n) (+= i 1) (block (continue)))"},
+		{"for (i = 0; i < n; i += 1) {}", "(for (= i 0) (< i n) (+= i 1) (block ))"},
+		{"for (;;) {}", "(for _ _ _ (block ))"},
+		{"for (x in [1, 2]) { f(x); }", "(for-in x (array 1 2) (block (f x)))"},
+		{"for (c in range(3)) {}", "(for-in c (range 3) (block ))"},
+		{"outer: for (x in xs) { while (x) { break outer; } }",
+			"(label outer (for-in x xs (block (while x (block (break outer))))))"},
+		{"a: while (1) { b: while (2) { continue a; } }",
+			"(label a (while 1 (block (label b (while 2 (block (continue a)))))))"},
+		{"while (1) { if (x) { break; } }", "(while 1 (block (if x (block (break)))))"},
+	}
+
+	for i, testcase := range input {
+		p := New(lexer.New(testcase.expr))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+		if len(program.Statements) != 1 {
+			t.Fatalf("[TC %d] program does not contain 1 statement. got=%d",
+				i, len(program.Statements))
+		}
+		if program.Statements[0].String() != testcase.tree {
+			t.Errorf("[TC %d] AST string didn't match. expected=%q, got=%q",
+				i, testcase.tree, program.Statements[0].String())
+		}
+	}
+
+	invalid := []struct {
+		expr string
+		code ErrorCode
+		err  string
+	}{
+		{"break;", BRANCH_OUTSIDE_LOOP, "at line:1, column:1, break outside loop"},
+		{"while (x) { fn() { continue; }; }", BRANCH_OUTSIDE_LOOP,
+			"at line:1, column:20, continue outside loop"},
+		{"for (;; break) {}", EXPECTED_EXPRESSION, "at line:1, column:9, expected expression, got=break"},
+		{"a: while (x) { break b; }", UNDEFINED_LABEL, "at line:1, column:22, undefined label b"},
+		{"while (x) { a: while (y) {} break a; }", UNDEFINED_LABEL,
+			"at line:1, column:35, undefined label a"},
+		{"a: x + 1;", INVALID_LABEL, "at line:1, column:4, expected loop after label a, got=IDENTIFIER"},
+		{"while (x) { break }", UNEXPECTED_TOKEN, "at line:1, column:19, expected ;, got=}"},
+		{"for (x in xs {}", UNEXPECTED_TOKEN, "at line:1, column:14, expected ), got={"},
+		{"for (let i = 0, i < 3; i += 1) {}", UNEXPECTED_TOKEN, "at line:1, column:15, expected ;, got=,"},
+	}
+	for i, testcase := range invalid {
+		p := New(lexer.New(testcase.expr))
+		p.ParseProgram()
+		if len(p.Errors) != 1 || p.Errors[0].Error() != testcase.err ||
+			p.Errors[0].(*SyntaxError).Code != testcase.code {
+			t.Errorf("[TC %d] expected error %q. got=%v", i, testcase.err, p.Errors)
+		}
+	}
+
+	// a loop with a broken header is skipped as a whole
+	p := New(lexer.New("while (x { y; } let a = 1; for (;; +) { z; } b;"))
+	program := p.ParseProgram()
+	if len(p.Errors) != 2 {
+		t.Fatalf("expected 2 errors. got=%v", p.Errors)
+	}
+	if program.String() != "(prog (let a 1) b)" {
+		t.Errorf("AST string didn't match. got=%q", program.String())
+	}
+}
+
+func TestNodePositions(t *testing.T) {
+	input := []struct {
+		src  string
+		span string // source of the first statement
+		sub  string // source of the first child expression, if any
+	}{
+		{"-a * b;", "-a * b", "-a"},
+		{"a + (b * c);", "a + (b * c)", "a"},
+		{"!f(1, g(2))(3);", "!f(1, g(2))(3)", "f(1, g(2))(3)"},
+		{"[1, 2][0] ;", "[1, 2][0]", "[1, 2]"},
+		{"a[1:] + a[:2];", "a[1:] + a[:2]", "a[1:]"},
+		{`{"k": v}["k"];`, `{"k": v}["k"]`, `{"k": v}`},
+		{"x += y = 1;", "x += y = 1", "x"},
+		{"fn(x) { x }(1);", "fn(x) { x }(1)", "fn(x) { x }"},
+		{"if (a) { b } else { c };", "if (a) { b } else { c }", ""},
+		{`"größe ${x}" + y;`, `"größe ${x}" + y`, `"größe ${x}"`},
+		{"let x = 1 + 2;", "let x = 1 + 2", ""},
+		{"return x * 2;", "return x * 2", ""},
+		{"outer: while (x) { break outer; }", "outer: while (x) { break outer; }", ""},
+		{"for (x in xs) {}", "for (x in xs) {}", ""},
+	}
+
+	for i, tt := range input {
+		l := lexer.New(tt.src)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+		file := l.File()
+		text := func(n ast.Node) string {
+			return tt.src[file.Offset(n.Pos()):file.Offset(n.End())]
+		}
+		stmt := program.Statements[0]
+		if got := text(stmt); got != tt.span {
+			t.Errorf("[TC %d] span wrong. expected=%q, got=%q", i, tt.span, got)
+		}
+		if got := text(program); got != tt.span {
+			t.Errorf("[TC %d] program span wrong. expected=%q, got=%q", i, tt.span, got)
+		}
+		if tt.sub == "" {
+			continue
+		}
+		var child ast.Node
+		switch expr := stmt.(*ast.ExpressionStatement).Expression.(type) {
+		case *ast.PrefixExpr:
+			child = expr.Expression
+		case *ast.InfixExpr:
+			child = expr.Left
+		case *ast.FunctionCall:
+			child = expr.Function
+		case *ast.IndexExpr:
+			child = expr.Left
+		case *ast.AssignExpr:
+			child = expr.Target
+		}
+		if got := text(child); got != tt.sub {
+			t.Errorf("[TC %d] child span wrong. expected=%q, got=%q", i, tt.sub, got)
+		}
+	}
+}
+
+func TestCustomOperators(t *testing.T) {
+	input := []struct {
+		expr string
+		tree string
+	}{
+		{"a ^ b ^ c;", "(^ a (^ b c))"},
+		{"a ^ b * c;", "(^ a (* b c))"},
+		{"a * b ^ c;", "(^ (* a b) c)"},
+		{"a | b | c;", "(| (| a b) c)"},
+		{"a | b ^ c + d;", "(| a (^ b (+ c d)))"},
+		{"+a * b;", "(* (+ a) b)"},
+		{"a! + 1;", "(+ (! a) 1)"},
+	}
+
+	for i, testcase := range input {
+		p := New(lexer.New(testcase.expr))
+		p.RegisterInfix(token.CARET, Operator{PREC_LESSGREATER, RIGHT_ASSOC, ParseInfixExpression})
+		p.RegisterInfix(token.PIPE, Operator{PREC_EQUALS, LEFT_ASSOC, ParseInfixExpression})
+		p.RegisterPrefix(token.PLUS, ParsePrefixExpression)
+		// a postfix operator
+		p.RegisterInfix(token.EXCLAMATION, Operator{PREC_CALL, LEFT_ASSOC,
+			func(p *Parser, left ast.Expression) ast.Expression {
+				expr := &ast.PrefixExpr{Operator: p.CurToken(), Expression: left}
+				p.Advance()
+				return expr
+			}})
+
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+		if len(program.Statements) != 1 {
+			t.Fatalf("[TC %d] program does not contain 1 statement. got=%d",
+				i, len(program.Statements))
+		}
+		if program.Statements[0].String() != testcase.tree {
+			t.Errorf("[TC %d] AST string didn't match. expected=%q, got=%q",
+				i, testcase.tree, program.Statements[0].String())
+		}
+	}
+
+	// operators are registered per parser
+	p := New(lexer.New("a ^ b ^ c;"))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+	if program.String() != "(prog (^ (^ a b) c))" {
+		t.Errorf("default operator changed. got=%q", program.String())
+	}
+	p = New(lexer.New("a!;"))
+	p.ParseProgram()
+	if len(p.Errors) == 0 {
+		t.Errorf("postfix ! parsed without being registered")
+	}
+	if op, ok := p.Operator(token.PLUS); !ok || op.Precedence != PREC_SUM || op.Associativity != LEFT_ASSOC {
+		t.Errorf("operator + wrong. got=%+v", op)
+	}
+}
@@ -3,34 +3,69 @@ package repl
 import (
 	"bufio"
 	"fmt"
+	"github.com/px86/monkey/diag"
 	"github.com/px86/monkey/evaluator"
 	"github.com/px86/monkey/lexer"
+	"github.com/px86/monkey/object"
 	"github.com/px86/monkey/parser"
+	"github.com/px86/monkey/token"
 	"io"
-	"os"
+	"strings"
 )
 
-func Start(in io.Reader, out io.Writer) {
+// Name used in place of a file name when reporting errors in REPL input.
+const STDIN_NAME = "<stdin>"
+
+// Evaluate the lines read from in, writing their values to out, and syntax
+// and runtime errors to errOut.
+func Start(in io.Reader, out, errOut io.Writer) {
 	scanner := bufio.NewScanner(in)
+	env := object.NewEnvironment()
+	// every line is a file of its own, so that functions defined on
+	// earlier lines can still be shown in error messages
+	fset := token.NewFileSet()
+	printer := diag.NewPrinter(errOut, fset)
 	for {
 		scanned := scanner.Scan()
 		if !scanned {
 			return
 		}
 		line := scanner.Text()
-		l := lexer.New(line)
+		file := fset.AddFile(STDIN_NAME)
+		printer.AddSource(file, line)
+		l := lexer.NewFileReader(file, strings.NewReader(line))
 		p := parser.New(l)
 		prog := p.ParseProgram()
 		if len(p.Errors) > 0 {
 			for _, err := range p.Errors {
-				fmt.Fprintf(os.Stderr, "%s\n", err)
+				printer.Print(diag.FromError(err))
 			}
-			return
+			continue
+		}
+		result := evaluator.Eval(prog, env)
+		if errobj, ok := result.(*object.Error); ok {
+			PrintError(printer, fset, errobj)
+			continue
 		}
-		result := evaluator.Eval(prog)
 		if result != nil {
 			io.WriteString(out, result.Inspect())
 			io.WriteString(out, "\n")
 		}
 	}
 }
+
+// Print a runtime error, with its kind as the code, and a note for every
+// function of the monkey call stack if the error occurred inside a function.
+func PrintError(printer *diag.Printer, fset *token.FileSet, err *object.Error) {
+	d := diag.Diagnostic{
+		Code:    string(err.Kind),
+		Pos:     err.Token.Pos,
+		End:     err.Token.End,
+		Message: err.Message,
+	}
+	for _, frame := range err.Stack {
+		d.Notes = append(d.Notes, fmt.Sprintf("in %s, called at %s",
+			frame.Function, fset.Position(frame.CallSite.Pos)))
+	}
+	printer.Print(d)
+}
@@ -0,0 +1,175 @@
+package token
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Pos is a compact encoding of a source position within a FileSet. It
+// can be converted into a Position with FileSet.Position. Each file of a
+// set owns the range [base, base+size] of Pos values, so positions of
+// different files never collide.
+type Pos int
+
+// NoPos is the zero value of Pos; it is not part of any file.
+const NoPos Pos = 0
+
+func (p Pos) IsValid() bool {
+	return p != NoPos
+}
+
+// Position is a decoded source position.
+type Position struct {
+	Filename string
+	Offset   int // byte offset, starting at 0
+	Line     int // line number, starting at 1
+	Column   int // column number, starting at 0, counted in runes
+}
+
+func (pos Position) IsValid() bool {
+	return pos.Line > 0
+}
+
+// String returns "file:line:column", or "line:column" if there is no
+// filename. The column is shown starting at 1, as editors expect.
+func (pos Position) String() string {
+	if !pos.IsValid() {
+		return "-"
+	}
+	if pos.Filename == "" {
+		return fmt.Sprintf("%d:%d", pos.Line, pos.Column+1)
+	}
+	return fmt.Sprintf("%s:%d:%d", pos.Filename, pos.Line, pos.Column+1)
+}
+
+// File records the layout of one source file: where its lines start and
+// where it contains multi-byte runes, which is enough to turn byte offsets
+// into lines and rune columns without keeping the source around. The
+// lexer fills it in while reading, so a file grows as it is lexed.
+type File struct {
+	name  string
+	base  int
+	size  int
+	lines []int // offsets of the first byte of each line
+	wide  []int // offsets of multi-byte runes; the size of each follows
+}
+
+func (f *File) Name() string {
+	return f.name
+}
+
+// Base returns the Pos value of the first byte of the file.
+func (f *File) Base() int {
+	return f.base
+}
+
+// Size returns the number of bytes of the file seen so far.
+func (f *File) Size() int {
+	return f.size
+}
+
+// Grow extends the file to size bytes.
+func (f *File) Grow(size int) {
+	if size > f.size {
+		f.size = size
+	}
+}
+
+// AddLine records that a new line starts at offset. Offsets must be added
+// in increasing order.
+func (f *File) AddLine(offset int) {
+	if n := len(f.lines); n == 0 || f.lines[n-1] < offset {
+		f.lines = append(f.lines, offset)
+	}
+	f.Grow(offset)
+}
+
+// AddRune records a rune of size bytes at offset, so that columns can be
+// counted in runes. Only runes longer than one byte need to be added.
+func (f *File) AddRune(offset, size int) {
+	if size > 1 {
+		f.wide = append(f.wide, offset, size)
+	}
+	f.Grow(offset + size)
+}
+
+// Pos returns the Pos value for the byte offset in the file.
+func (f *File) Pos(offset int) Pos {
+	return Pos(f.base + offset)
+}
+
+// Offset returns the byte offset of p in the file.
+func (f *File) Offset(p Pos) int {
+	return int(p) - f.base
+}
+
+// LineStart returns the byte offset of the first byte of line.
+func (f *File) LineStart(line int) int {
+	if line < 1 || line > len(f.lines) {
+		return -1
+	}
+	return f.lines[line-1]
+}
+
+// Position decodes p, which must belong to the file.
+func (f *File) Position(p Pos) Position {
+	offset := f.Offset(p)
+	line := sort.Search(len(f.lines), func(i int) bool { return f.lines[i] > offset })
+	start := f.lines[line-1]
+
+	column := offset - start
+	i := sort.Search(len(f.wide)/2, func(i int) bool { return f.wide[2*i] >= start })
+	for ; i < len(f.wide)/2 && f.wide[2*i] < offset; i++ {
+		column -= f.wide[2*i+1] - 1
+	}
+	return Position{Filename: f.name, Offset: offset, Line: line, Column: column}
+}
+
+// FileSet is a collection of source files sharing one Pos space.
+type FileSet struct {
+	files []*File
+}
+
+func NewFileSet() *FileSet {
+	return &FileSet{}
+}
+
+// AddFile adds a new, empty file to the set. Its positions start after the
+// end of the previously added file, so that file must have been read
+// completely.
+func (s *FileSet) AddFile(filename string) *File {
+	base := 1
+	if n := len(s.files); n > 0 {
+		last := s.files[n-1]
+		base = last.base + last.size + 1
+	}
+	f := &File{name: filename, base: base, lines: []int{0}}
+	s.files = append(s.files, f)
+	return f
+}
+
+// File returns the file containing p, or nil.
+func (s *FileSet) File(p Pos) *File {
+	i := sort.Search(len(s.files), func(i int) bool { return s.files[i].base > int(p) })
+	if i == 0 || !p.IsValid() {
+		return nil
+	}
+	if f := s.files[i-1]; int(p) <= f.base+f.size {
+		return f
+	}
+	return nil
+}
+
+// Position decodes p, returning an invalid Position if p does not belong
+// to any file of the set.
+func (s *FileSet) Position(p Pos) Position {
+	if f := s.File(p); f != nil {
+		return f.Position(p)
+	}
+	return Position{}
+}
+
+// Files returns the files of the set, in the order they were added.
+func (s *FileSet) Files() []*File {
+	return s.files
+}
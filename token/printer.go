@@ -20,10 +20,14 @@ func AsString(toktype TokenType) string {
 		return "UNKNOWN"
 	case EOF:
 		return "EOF"
+	case ILLEGAL:
+		return "ILLEGAL"
 	case ASTERISK:
 		return "*"
 	case COMMA:
 		return ","
+	case COLON:
+		return ":"
 	case MINUS:
 		return "-"
 	case PLUS:
@@ -70,12 +74,34 @@ func AsString(toktype TokenType) string {
 		return "||"
 	case CARET:
 		return "^"
+	case PLUS_EQUAL:
+		return "+="
+	case MINUS_EQUAL:
+		return "-="
+	case ASTERISK_EQUAL:
+		return "*="
+	case SLASH_EQUAL:
+		return "/="
+	case ASTERISK_ASTERISK:
+		return "**"
+	case PERCENT:
+		return "%"
+	case LESSER_LESSER:
+		return "<<"
+	case GREATER_GREATER:
+		return ">>"
+	case TILDE:
+		return "~"
 	case INTEGER:
 		return "INTEGER"
 	case FLOAT:
 		return "FLOAT"
 	case STRING_LITERAL:
 		return "STRING"
+	case INTERPOLATED_STRING:
+		return "INTERPOLATED_STRING"
+	case DOC_COMMENT:
+		return "DOC_COMMENT"
 	case IDENTIFIER:
 		return "IDENTIFIER"
 	case KW_LET:
@@ -88,6 +114,16 @@ func AsString(toktype TokenType) string {
 		return "fn"
 	case KW_RETURN:
 		return "return"
+	case KW_WHILE:
+		return "while"
+	case KW_FOR:
+		return "for"
+	case KW_IN:
+		return "in"
+	case KW_BREAK:
+		return "break"
+	case KW_CONTINUE:
+		return "continue"
 
 	default:
 		return ""
@@ -102,10 +138,14 @@ func TypeStr2(toktype TokenType) string {
 		return "UNKNOWN"
 	case EOF:
 		return "EOF"
+	case ILLEGAL:
+		return "ILLEGAL"
 	case ASTERISK:
 		return "ASTERISK"
 	case COMMA:
 		return "COMMA"
+	case COLON:
+		return "COLON"
 	case MINUS:
 		return "MINUS"
 	case PLUS:
@@ -152,12 +192,34 @@ func TypeStr2(toktype TokenType) string {
 		return "LOGICAL_OR"
 	case CARET:
 		return "XOR"
+	case PLUS_EQUAL:
+		return "PLUS_ASSIGN"
+	case MINUS_EQUAL:
+		return "MINUS_ASSIGN"
+	case ASTERISK_EQUAL:
+		return "MULTIPLY_ASSIGN"
+	case SLASH_EQUAL:
+		return "DIVIDE_ASSIGN"
+	case ASTERISK_ASTERISK:
+		return "POWER"
+	case PERCENT:
+		return "MODULO"
+	case LESSER_LESSER:
+		return "LEFT_SHIFT"
+	case GREATER_GREATER:
+		return "RIGHT_SHIFT"
+	case TILDE:
+		return "BITWISE_NOT"
 	case INTEGER:
 		return "INTEGER"
 	case FLOAT:
 		return "FLOAT"
 	case STRING_LITERAL:
 		return "STRING"
+	case INTERPOLATED_STRING:
+		return "INTERPOLATED_STRING"
+	case DOC_COMMENT:
+		return "DOC_COMMENT"
 	case IDENTIFIER:
 		return "IDENTIFIER"
 	case KW_LET:
@@ -170,6 +232,16 @@ func TypeStr2(toktype TokenType) string {
 		return "FUNCTION"
 	case KW_RETURN:
 		return "RETURN"
+	case KW_WHILE:
+		return "WHILE"
+	case KW_FOR:
+		return "FOR"
+	case KW_IN:
+		return "IN"
+	case KW_BREAK:
+		return "BREAK"
+	case KW_CONTINUE:
+		return "CONTINUE"
 
 	default:
 		return ""
@@ -5,9 +5,13 @@ type TokenType int
 const (
 	UNKNOWN TokenType = iota + 1
 	EOF
+	ILLEGAL // invalid input, reported by the lexer
 
 	ASTERISK            // *
+	ASTERISK_ASTERISK   // **
+	PERCENT             // %
 	COMMA               // ,
+	COLON               // :
 	MINUS               // -
 	PLUS                // +
 	SEMI_COLON          // ;
@@ -19,6 +23,10 @@ const (
 	LEFT_BRACKET        // [
 	RIGHT_BRACKET       // ]
 	EQUAL               // =
+	PLUS_EQUAL          // +=
+	MINUS_EQUAL         // -=
+	ASTERISK_EQUAL      // *=
+	SLASH_EQUAL         // /=
 	EQUAL_EQUAL         // ==
 	EXCLAMATION         // !
 	EXCLAMATION_EQUAL   // !=
@@ -26,6 +34,8 @@ const (
 	GREATER_THAN_EQUAL  // >=
 	LESSER_THAN         // <
 	LESSER_THAN_EQUAL   // <=
+	LESSER_LESSER       // <<
+	GREATER_GREATER     // >>
 	TILDE               // ~
 	AMPERSAND           // &
 	AMPERSAND_AMPERSAND // &&
@@ -36,6 +46,8 @@ const (
 	INTEGER
 	FLOAT
 	STRING_LITERAL
+	INTERPOLATED_STRING // "...${expr}...", Value holds a []StringPart
+	DOC_COMMENT         // /// comment, Value holds its text
 
 	IDENTIFIER
 	KW_LET      // let
@@ -45,23 +57,44 @@ const (
 	KW_RETURN   // return
 	KW_TRUE     // true
 	KW_FALSE    // false
+	KW_WHILE    // while
+	KW_FOR      // for
+	KW_IN       // in
+	KW_BREAK    // break
+	KW_CONTINUE // continue
 )
 
 var kwMap = map[string]TokenType{
-	"fn":     KW_FUNCTION,
-	"let":    KW_LET,
-	"if":     KW_IF,
-	"else":   KW_ELSE,
-	"return": KW_RETURN,
-	"true":   KW_TRUE,
-	"false":  KW_FALSE,
+	"fn":       KW_FUNCTION,
+	"let":      KW_LET,
+	"if":       KW_IF,
+	"else":     KW_ELSE,
+	"return":   KW_RETURN,
+	"true":     KW_TRUE,
+	"false":    KW_FALSE,
+	"while":    KW_WHILE,
+	"for":      KW_FOR,
+	"in":       KW_IN,
+	"break":    KW_BREAK,
+	"continue": KW_CONTINUE,
+}
+
+// StringPart is a piece of an interpolated string: either literal text,
+// or the tokens of an embedded ${expression}, terminated by an EOF token.
+type StringPart struct {
+	Text   string
+	Tokens []Token // nil for literal text
 }
 
 type Token struct {
 	Type   TokenType
 	Value  any
-	Line   int // line on which token starts
-	Column int // column on which token starts
+	File   string // name of the source file, empty for sources without one
+	Line   int    // line on which token starts
+	Column int    // column on which token starts, counted in runes
+	Offset int    // byte offset at which token starts
+	Pos    Pos    // position of the first byte of the token in its FileSet
+	End    Pos    // position immediately after the token
 }
 
 func IsKeyword(s string) (kw TokenType, ok bool) {